@@ -0,0 +1,355 @@
+package gormysql
+
+import (
+	"context"
+	"reflect"
+)
+
+// DefaultCallback holds the processors every DB opened afterwards starts
+// with.
+var DefaultCallback = &Callback{}
+
+// Callback is a registry of named processors run, in order, for the create,
+// update, delete and query operations; query processors also run for
+// Scan, Rows, Count, Pluck and the aggregates. Register processors through
+// db.Callback(), e.g.
+//
+//	db.Callback().Create().Before("gormysql:create").Register("audit", fn)
+type Callback struct {
+	processors []*CallbackProcessor
+	creates    []*func(d *Do)
+	updates    []*func(d *Do)
+	deletes    []*func(d *Do)
+	queries    []*func(d *Do)
+}
+
+// CallbackProcessor registers, replaces or removes a processor of one
+// operation kind.
+type CallbackProcessor struct {
+	name      string
+	before    string
+	after     string
+	kind      string
+	processor *func(d *Do)
+	callback  *Callback
+}
+
+func (c *Callback) Create() *CallbackProcessor {
+	return &CallbackProcessor{kind: "create", callback: c}
+}
+
+func (c *Callback) Update() *CallbackProcessor {
+	return &CallbackProcessor{kind: "update", callback: c}
+}
+
+func (c *Callback) Delete() *CallbackProcessor {
+	return &CallbackProcessor{kind: "delete", callback: c}
+}
+
+func (c *Callback) Query() *CallbackProcessor {
+	return &CallbackProcessor{kind: "query", callback: c}
+}
+
+// Before makes the processor registered next run before the named one.
+func (cp *CallbackProcessor) Before(name string) *CallbackProcessor {
+	cp.before = name
+	return cp
+}
+
+// After makes the processor registered next run after the named one.
+func (cp *CallbackProcessor) After(name string) *CallbackProcessor {
+	cp.after = name
+	return cp
+}
+
+// Register adds fn under name. A processor already registered under the
+// same name is replaced.
+func (cp *CallbackProcessor) Register(name string, fn func(d *Do)) {
+	cp.callback.remove(cp.kind, name)
+	cp.name = name
+	cp.processor = &fn
+	cp.callback.processors = append(cp.callback.processors, cp)
+	cp.callback.reorder()
+}
+
+// Remove drops the named processor.
+func (cp *CallbackProcessor) Remove(name string) {
+	cp.callback.remove(cp.kind, name)
+	cp.callback.reorder()
+}
+
+// Replace swaps the function of the named processor, keeping its position.
+func (cp *CallbackProcessor) Replace(name string, fn func(d *Do)) {
+	for _, p := range cp.callback.processors {
+		if p.kind == cp.kind && p.name == name {
+			p.processor = &fn
+		}
+	}
+	cp.callback.reorder()
+}
+
+// Get returns the function of the named processor, or nil.
+func (cp *CallbackProcessor) Get(name string) func(d *Do) {
+	for _, p := range cp.callback.processors {
+		if p.kind == cp.kind && p.name == name {
+			return *p.processor
+		}
+	}
+	return nil
+}
+
+func (c *Callback) clone() *Callback {
+	clone := &Callback{}
+	for _, p := range c.processors {
+		cp := *p
+		cp.callback = clone
+		clone.processors = append(clone.processors, &cp)
+	}
+	clone.reorder()
+	return clone
+}
+
+func (c *Callback) remove(kind, name string) {
+	var processors []*CallbackProcessor
+	for _, p := range c.processors {
+		if p.kind != kind || p.name != name {
+			processors = append(processors, p)
+		}
+	}
+	c.processors = processors
+}
+
+func (c *Callback) reorder() {
+	var creates, updates, deletes, queries []*CallbackProcessor
+	for _, p := range c.processors {
+		switch p.kind {
+		case "create":
+			creates = append(creates, p)
+		case "update":
+			updates = append(updates, p)
+		case "delete":
+			deletes = append(deletes, p)
+		case "query":
+			queries = append(queries, p)
+		}
+	}
+	c.creates = sortProcessors(creates)
+	c.updates = sortProcessors(updates)
+	c.deletes = sortProcessors(deletes)
+	c.queries = sortProcessors(queries)
+}
+
+// sortProcessors orders processors by registration, then moves those
+// registered with Before or After next to the processor they name.
+// Processors naming one that doesn't exist stay at the end.
+func sortProcessors(processors []*CallbackProcessor) (funcs []*func(d *Do)) {
+	var sorted, pending []*CallbackProcessor
+	exists := map[string]bool{}
+	for _, p := range processors {
+		exists[p.name] = true
+	}
+	for _, p := range processors {
+		if exists[p.before] || exists[p.after] {
+			pending = append(pending, p)
+		} else {
+			sorted = append(sorted, p)
+		}
+	}
+
+	indexOf := func(name string) int {
+		for i, p := range sorted {
+			if p.name == name {
+				return i
+			}
+		}
+		return -1
+	}
+	insert := func(i int, p *CallbackProcessor) {
+		sorted = append(sorted[:i], append([]*CallbackProcessor{p}, sorted[i:]...)...)
+	}
+
+	for len(pending) > 0 {
+		var rest []*CallbackProcessor
+		for _, p := range pending {
+			if i := indexOf(p.before); exists[p.before] && i >= 0 {
+				insert(i, p)
+			} else if i := indexOf(p.after); !exists[p.before] && i >= 0 {
+				// keep processors registered after the same one in order
+				i++
+				for i < len(sorted) && sorted[i].after == p.after {
+					i++
+				}
+				insert(i, p)
+			} else {
+				rest = append(rest, p)
+			}
+		}
+		if len(rest) == len(pending) {
+			// the remaining processors refer to each other in a cycle
+			sorted = append(sorted, rest...)
+			break
+		}
+		pending = rest
+	}
+
+	for _, p := range sorted {
+		funcs = append(funcs, p.processor)
+	}
+	return
+}
+
+//--------- Do accessors for processors ---------
+
+// Value returns the value the operation works on.
+func (d *Do) Value() any {
+	return d.value
+}
+
+// TableName returns the table of the operation's model.
+func (d *Do) TableName() string {
+	return d.tableName()
+}
+
+// Sql returns the statement built for the operation. It is empty before
+// the "gormysql:create", "gormysql:update", "gormysql:delete" or
+// "gormysql:query" processor has run.
+func (d *Do) Sql() string {
+	return d.sql
+}
+
+// SqlVars returns the values bound to the statement's placeholders.
+func (d *Do) SqlVars() []any {
+	return d.sqlVars
+}
+
+func (d *Do) Context() context.Context {
+	return d.ctx
+}
+
+// RowsAffected returns the number of rows the executed statement changed.
+func (d *Do) RowsAffected() int64 {
+	if d.sqlResult == nil {
+		return 0
+	}
+	affected, _ := d.sqlResult.RowsAffected()
+	return affected
+}
+
+// AddError records err on the operation and its chain.
+func (d *Do) AddError(err error) error {
+	return d.err(err)
+}
+
+func (d *Do) HasError() bool {
+	return d.hasError()
+}
+
+//--------- default processors ---------
+
+func init() {
+	DefaultCallback.Create().Register("gormysql:before_create", beforeCreateCallback)
+	DefaultCallback.Create().Register("gormysql:create", createCallback)
+	DefaultCallback.Create().Register("gormysql:after_create", afterCreateCallback)
+
+	DefaultCallback.Update().Register("gormysql:before_update", beforeUpdateCallback)
+	DefaultCallback.Update().Register("gormysql:update", updateCallback)
+	DefaultCallback.Update().Register("gormysql:after_update", afterUpdateCallback)
+
+	DefaultCallback.Delete().Register("gormysql:before_delete", beforeDeleteCallback)
+	DefaultCallback.Delete().Register("gormysql:delete", deleteCallback)
+	DefaultCallback.Delete().Register("gormysql:after_delete", afterDeleteCallback)
+
+	DefaultCallback.Query().Register("gormysql:query", queryCallback)
+	DefaultCallback.Query().Register("gormysql:after_query", afterQueryCallback)
+}
+
+func beforeCreateCallback(d *Do) {
+	if d.hasError() || d.err(d.model.callMethod("BeforeSave")) != nil {
+		return
+	}
+	d.err(d.model.callMethod("BeforeCreate"))
+}
+
+func createCallback(d *Do) {
+	if !d.hasError() {
+		d.create()
+	}
+}
+
+func afterCreateCallback(d *Do) {
+	if d.hasError() || d.err(d.model.callMethod("AfterCreate")) != nil {
+		return
+	}
+	d.err(d.model.callMethod("AfterSave"))
+}
+
+func beforeUpdateCallback(d *Do) {
+	if d.hasError() || d.skipHooks || d.err(d.model.callMethod("BeforeSave")) != nil {
+		return
+	}
+	d.err(d.model.callMethod("BeforeUpdate"))
+}
+
+func updateCallback(d *Do) {
+	if !d.hasError() {
+		d.update()
+	}
+}
+
+func afterUpdateCallback(d *Do) {
+	if d.hasError() || d.skipHooks || d.err(d.model.callMethod("AfterUpdate")) != nil {
+		return
+	}
+	d.err(d.model.callMethod("AfterSave"))
+}
+
+func beforeDeleteCallback(d *Do) {
+	if !d.hasError() {
+		d.err(d.model.callMethod("BeforeDelete"))
+	}
+}
+
+func deleteCallback(d *Do) {
+	if !d.hasError() {
+		d.delete()
+	}
+}
+
+func afterDeleteCallback(d *Do) {
+	if !d.hasError() {
+		d.err(d.model.callMethod("AfterDelete"))
+	}
+}
+
+func queryCallback(d *Do) {
+	if !d.hasError() {
+		d.query()
+	}
+}
+
+func afterQueryCallback(d *Do) {
+	// Scan, Count, Rows and the like don't load the model
+	if d.hasError() || d.scanOut != nil || d.keepRows {
+		return
+	}
+	result := reflect.Indirect(reflect.ValueOf(d.value))
+	if result.Kind() != reflect.Slice {
+		d.err(d.model.callMethod("AfterFind"))
+		return
+	}
+	for i := 0; i < result.Len(); i++ {
+		elem := result.Index(i)
+		if elem.Kind() != reflect.Ptr {
+			elem = elem.Addr()
+		}
+		if d.err((&Model{data: elem.Interface()}).callMethod("AfterFind")) != nil {
+			return
+		}
+	}
+}
+
+func (d *Do) callCallbacks(funcs []*func(d *Do)) {
+	for _, f := range funcs {
+		(*f)(d)
+	}
+}
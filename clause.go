@@ -0,0 +1,100 @@
+package gormysql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SqlExpr is a SQL expression used in place of a value, built with Expr.
+type SqlExpr struct {
+	sql  string
+	vars []any
+}
+
+// Expr builds a SQL expression to use as a value, like
+//
+//	db.Model(&product).Update("price", gormysql.Expr("price * ?", 2))
+//
+// Vars are bound to its placeholders like in Where.
+func Expr(sql string, vars ...any) SqlExpr {
+	return SqlExpr{sql: sql, vars: vars}
+}
+
+// OnConflict sets what inserts do with rows colliding with a primary or
+// unique key, through ON DUPLICATE KEY UPDATE or INSERT IGNORE:
+//
+//	db.OnConflict(gormysql.OnConflict{
+//		DoUpdates:   []string{"name"},
+//		Assignments: map[string]any{"visits": gormysql.Expr("visits + 1")},
+//	}).Create(&users)
+type OnConflict struct {
+	// UpdateAll updates every inserted column but the primary keys and
+	// CreatedAt with the inserted values.
+	UpdateAll bool
+	// DoUpdates lists the columns updated with the inserted values.
+	DoUpdates []string
+	// Assignments maps columns to the values, or Expr, they're updated with.
+	Assignments map[string]any
+	// DoNothing leaves colliding rows as they are, with INSERT IGNORE.
+	DoNothing bool
+}
+
+// onConflictSql returns the ON DUPLICATE KEY UPDATE clause of an insert of
+// columns. The auto increment key is assigned to itself through
+// LAST_INSERT_ID so that the id of an updated row is reported as inserted.
+func (d *Do) onConflictSql(columns []string) string {
+	if d.onConflict == nil || d.onConflict.DoNothing {
+		return ""
+	}
+
+	var sqls []string
+	updated := map[string]bool{}
+	setValues := func(column string) {
+		if !updated[column] {
+			updated[column] = true
+			sqls = append(sqls, fmt.Sprintf("%v = VALUES(%v)", column, column))
+		}
+	}
+	if d.onConflict.UpdateAll {
+		skipped := map[string]bool{}
+		for _, field := range d.model.structFields() {
+			if field.IsPrimaryKey || field.AutoCreateTime {
+				skipped[field.DbName] = true
+			}
+		}
+		for _, column := range columns {
+			if !skipped[column] {
+				setValues(column)
+			}
+		}
+	}
+	for _, column := range d.onConflict.DoUpdates {
+		setValues(column)
+	}
+
+	var assigned []string
+	for column := range d.onConflict.Assignments {
+		assigned = append(assigned, column)
+	}
+	sort.Strings(assigned)
+	for _, column := range assigned {
+		if !updated[column] {
+			updated[column] = true
+			sqls = append(sqls, fmt.Sprintf("%v = %v", column, d.addToVars(d.onConflict.Assignments[column])))
+		}
+	}
+
+	if field := d.model.autoIncrementField(); field != nil && len(d.model.elements()) == 1 {
+		sqls = append(sqls, fmt.Sprintf("%v = LAST_INSERT_ID(%v)", field.DbName, field.DbName))
+	} else if len(sqls) == 0 {
+		// nothing to update, but the collision mustn't fail the insert
+		if key := d.model.primaryKeyDb(); len(key) > 0 {
+			sqls = append(sqls, fmt.Sprintf("%v = %v", key, key))
+		}
+	}
+	if len(sqls) == 0 {
+		return ""
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sqls, ",")
+}
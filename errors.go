@@ -0,0 +1,66 @@
+package gormysql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	// ErrRecordNotFound is returned by First when no record matches.
+	ErrRecordNotFound = errors.New("Record not found!")
+
+	// ErrMissingWhereClause is returned by updates, deletes and restores
+	// that would reach every record, having neither conditions nor a
+	// primary key.
+	ErrMissingWhereClause = errors.New("Missing where condition")
+
+	// The errors below classify the errors returned by MySQL. Check for them
+	// with errors.Is.
+	ErrDuplicatedKey      = errors.New("Duplicated key")
+	ErrForeignKeyViolated = errors.New("Foreign key violated")
+	ErrDeadlock           = errors.New("Deadlock found")
+	ErrLockWaitTimeout    = errors.New("Lock wait timeout exceeded")
+)
+
+// SqlError is an error returned by the database with the statement that
+// caused it. It matches both Kind, when the error could be classified, and
+// the driver error with errors.Is and errors.As.
+type SqlError struct {
+	Kind    error
+	Err     error
+	Sql     string
+	SqlVars []any
+}
+
+func (e *SqlError) Error() string {
+	return fmt.Sprintf("%v (sql: %v)", e.Err, e.Sql)
+}
+
+func (e *SqlError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// classifyError returns the sentinel error matching the MySQL error number
+// of err, or nil.
+func classifyError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+	switch mysqlErr.Number {
+	case 1062:
+		return ErrDuplicatedKey
+	case 1451, 1452:
+		return ErrForeignKeyViolated
+	case 1213:
+		return ErrDeadlock
+	case 1205:
+		return ErrLockWaitTimeout
+	}
+	return nil
+}
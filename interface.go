@@ -0,0 +1,20 @@
+package gormysql
+
+import (
+	"context"
+	"database/sql"
+)
+
+type sqlCommon interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type sqlDb interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+type sqlTx interface {
+	Commit() error
+	Rollback() error
+}
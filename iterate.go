@@ -0,0 +1,58 @@
+//go:build go1.23
+
+package gormysql
+
+import (
+	"iter"
+	"reflect"
+)
+
+// Iterate streams the records matching the conditions of the chain, one row
+// at a time, so that large results needn't fit in memory:
+//
+//	for user, err := range gormysql.Iterate[User](db.Where("age > ?", 20)) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// T can be anything Scan accepts a slice of. The table is the one of the
+// chain's model, or of T when the chain has none. Breaking out of the loop
+// closes the rows.
+func Iterate[T any](c *Chain) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		model := c.model
+		if model == nil {
+			model = &zero
+		}
+		do := c.do(model)
+		rows, err := do.queryRows()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			yield(zero, do.dbErr(err))
+			return
+		}
+		fieldIndexes := scanFieldIndexes(reflect.TypeOf((*T)(nil)).Elem(), c.naming)
+		for rows.Next() {
+			var value T
+			if err := scanRow(rows, columns, fieldIndexes, reflect.ValueOf(&value).Elem()); err != nil {
+				yield(zero, do.err(err))
+				return
+			}
+			if !yield(value, nil) {
+				return
+			}
+		}
+		if err := do.dbErr(rows.Err()); err != nil {
+			yield(zero, err)
+		}
+	}
+}
@@ -2,32 +2,68 @@ package gormysql
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"reflect"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type (
 	DB struct {
-		db *sql.DB
+		// NamingStrategy maps struct and field names to table and column
+		// names. DefaultNamingStrategy is used when it is nil.
+		NamingStrategy NamingStrategy
+
+		db         sqlCommon
+		ctx        context.Context
+		callbacks  *Callback
+		savepoints int
 	}
 	Chain struct {
-		db     *sql.DB
-		Errors []error
-		Error  error
-		value  any
-
-		whereClause []map[string]any
-		orderStrs   []string
+		db         sqlCommon
+		ctx        context.Context
+		naming     NamingStrategy
+		callbacks  *Callback
+		savepoints int
+		Errors     []error
+		Error      error
+		// RowsAffected is the number of rows changed by the last statement
+		// executed, or found by the last query.
+		RowsAffected int64
+		value        any
+		model        any
+		tableStr     string
+		onConflict   *OnConflict
+		attrs        []any
+		assigns      []any
+		unscoped     bool
+
+		whereClause  []map[string]any
+		orClause     []map[string]any
+		notClause    []map[string]any
+		havingClause []map[string]any
+		joinsClause  []map[string]any
+		rawClause    map[string]any
+		orderStrs    []string
+		selectStrs   []string
+		omitStrs     []string
+		groupStr     string
+		limitStr     string
+		offsetStr    string
+		distinct     bool
 	}
 	Do struct {
-		db        *sql.DB
+		db        sqlCommon
+		ctx       context.Context
 		chain     *Chain
 		sqlResult sql.Result
 		Errors    []error
@@ -35,37 +71,84 @@ type (
 		value     any
 		sql       string
 		sqlVars   []any
-
-		whereClause []map[string]any
-		orderStrs   []string
-		limitStr    string
+		tableStr  string
+		// updateAttrs holds the columns Updates writes, in place of every
+		// column of the model.
+		updateAttrs map[string]any
+		onConflict  *OnConflict
+		unscoped    bool
+		// skipHooks makes the update processors leave out the model's
+		// hooks, for UpdateColumns.
+		skipHooks bool
+		// scanOut receives the rows of queries that don't load the value,
+		// like Scan, Count and Pluck.
+		scanOut any
+		// keepRows makes the query leave its rows open in sqlRows, for Rows
+		// and Iterate to read.
+		keepRows bool
+		sqlRows  *sql.Rows
+		// countSubquery makes the query count the rows of the statement
+		// built otherwise.
+		countSubquery bool
+
+		// scopeClause holds conditions ANDed with all the others, Or
+		// included, e.g. the keyset of FindInBatches.
+		scopeClause  []map[string]any
+		whereClause  []map[string]any
+		orClause     []map[string]any
+		notClause    []map[string]any
+		havingClause []map[string]any
+		joinsClause  []map[string]any
+		rawClause    map[string]any
+		orderStrs    []string
+		selectStrs   []string
+		omitStrs     []string
+		groupStr     string
+		limitStr     string
+		offsetStr    string
+		distinct     bool
 	}
 	Model struct {
-		data any
+		data   any
+		naming NamingStrategy
 	}
 	Field struct {
-		Name           string
-		Value          any
-		SqlType        string
-		DbName         string
-		AutoCreateTime bool
-		AutoUpdateTime bool
-		IsPrimaryKey   bool
+		Name            string
+		Value           any
+		SqlType         string
+		DbName          string
+		Size            int
+		NotNull         bool
+		Unique          bool
+		Default         string
+		AutoCreateTime  bool
+		AutoUpdateTime  bool
+		SoftDelete      bool
+		IsPrimaryKey    bool
+		IsAutoIncrement bool
+
+		index     []int
+		fieldType reflect.Type
+		settings  map[string]string
 	}
 )
 
 func Open(source string) (db DB, err error) {
-	db.db, err = sql.Open("mysql", source)
-	return
+	return OpenWithDriver("mysql", source)
 }
 
 func OpenWithDriver(driverName, source string) (db DB, err error) {
-	db.db, err = sql.Open(driverName, source)
+	var sqlDB *sql.DB
+	sqlDB, err = sql.Open(driverName, source)
+	if err == nil {
+		db.db = sqlDB
+		db.callbacks = DefaultCallback.clone()
+	}
 	return
 }
 
-func (db *DB) Exec(sql string) *Chain {
-	return db.buildChanin().Exec(sql)
+func (db *DB) Exec(sql string, values ...any) *Chain {
+	return db.buildChanin().Exec(sql, values...)
 }
 
 func (db *DB) CreateTable(value any) *Chain {
@@ -76,380 +159,2099 @@ func (db *DB) Where(queryString any, args ...any) *Chain {
 	return db.buildChanin().Where(queryString, args...)
 }
 
-func (db *DB) First(out any, where ...any) *Chain {
-	return db.buildChanin().First(out, where...)
+func (db *DB) Or(queryString any, args ...any) *Chain {
+	return db.buildChanin().Or(queryString, args...)
 }
 
-func (db *DB) Find(out any, where ...any) *Chain {
-	return db.buildChanin().Find(out, where...)
+func (db *DB) Not(queryString any, args ...any) *Chain {
+	return db.buildChanin().Not(queryString, args...)
 }
 
-func (db *DB) Save(value any) *Chain {
-	return db.buildChanin().Save(value)
+func (db *DB) Order(value string) *Chain {
+	return db.buildChanin().Order(value)
 }
 
-func (db *DB) Delete(value any) *Chain {
-	return db.buildChanin().Delete(value)
+func (db *DB) Select(columns ...string) *Chain {
+	return db.buildChanin().Select(columns...)
 }
 
-func (db *DB) buildChanin() *Chain {
-	return &Chain{db: db.db}
+func (db *DB) Omit(columns ...string) *Chain {
+	return db.buildChanin().Omit(columns...)
 }
 
-//--------- Chain ---------
+func (db *DB) Distinct(columns ...string) *Chain {
+	return db.buildChanin().Distinct(columns...)
+}
 
-func (c *Chain) Exec(sql string) *Chain {
-	c.do(nil).exec(sql)
-	return c
+func (db *DB) Limit(value int) *Chain {
+	return db.buildChanin().Limit(value)
 }
 
-func (c *Chain) CreateTable(value any) *Chain {
-	c.do(value).createTable().exec()
-	return c
+func (db *DB) Offset(value int) *Chain {
+	return db.buildChanin().Offset(value)
 }
 
-func (c *Chain) Where(queryString any, args ...any) *Chain {
-	c.whereClause = append(c.whereClause, map[string]any{
-		"query": queryString,
-		"args":  args,
-	})
-	return c
+func (db *DB) Joins(query string, args ...any) *Chain {
+	return db.buildChanin().Joins(query, args...)
 }
 
-func (c *Chain) Order(value string) *Chain {
-	c.orderStrs = append(c.orderStrs, value)
-	return c
+func (db *DB) Raw(sql string, values ...any) *Chain {
+	return db.buildChanin().Raw(sql, values...)
 }
 
-func (c *Chain) First(out any, where ...any) *Chain {
-	do := c.do(out)
-	do.limitStr = "1"
-	do.query(where...)
-	return c
+func (db *DB) Updates(values any) *Chain {
+	return db.buildChanin().Updates(values)
 }
 
-func (c *Chain) Find(out any, where ...any) *Chain {
-	c.do(out).query(where...)
-	return c
+func (db *DB) OnConflict(onConflict OnConflict) *Chain {
+	return db.buildChanin().OnConflict(onConflict)
 }
 
-func (c *Chain) Save(value any) *Chain {
-	c.do(value).save()
-	return c
+func (db *DB) Unscoped() *Chain {
+	return db.buildChanin().Unscoped()
 }
 
-func (c *Chain) Delete(value any) *Chain {
-	c.do(value).delete()
-	return c
+func (db *DB) Restore(value any) *Chain {
+	return db.buildChanin().Restore(value)
 }
 
-func (c *Chain) do(value any) *Do {
-	var do Do
-	do.db = c.db
-	do.chain = c
-	do.whereClause = c.whereClause
-	do.orderStrs = c.orderStrs
+func (db *DB) Model(value any) *Chain {
+	return db.buildChanin().Model(value)
+}
 
-	c.value = value
-	do.setModel(value)
-	return &do
+func (db *DB) Table(name string) *Chain {
+	return db.buildChanin().Table(name)
 }
 
-func (c *Chain) err(err error) error {
-	if err != nil {
-		c.Errors = append(c.Errors, err)
-		c.Error = err
-	}
-	return err
+func (db *DB) FindInBatches(out any, batchSize int, fc func(tx *DB, batch int) error) *Chain {
+	return db.buildChanin().FindInBatches(out, batchSize, fc)
 }
 
-//--------- Do ---------
+func (db *DB) Group(value string) *Chain {
+	return db.buildChanin().Group(value)
+}
 
-func (d *Do) exec(sql ...string) {
-	var err error
-	if len(sql) == 0 {
-		d.sqlResult, err = d.db.Exec(d.sql, d.sqlVars...)
-	} else {
-		d.sqlResult, err = d.db.Exec(sql[0])
-	}
-	d.err(err)
+func (db *DB) First(out any, where ...any) *Chain {
+	return db.buildChanin().First(out, where...)
 }
 
-func (d *Do) prepareDeleteSql() {
-	d.sql = fmt.Sprintf(
-		"DELETE FROM %v %v",
-		d.tableName(),
-		d.combinedSql(),
-	)
+func (db *DB) Find(out any, where ...any) *Chain {
+	return db.buildChanin().Find(out, where...)
 }
 
-func (d *Do) save() {
-	if d.model.primaryKeyZero() {
-		d.create()
-	} else {
-		d.update()
-	}
-	return
+func (db *DB) Create(value any) *Chain {
+	return db.buildChanin().Create(value)
 }
 
-func (d *Do) delete() {
-	d.prepareDeleteSql()
-	if d.hasError() {
-		return
-	}
-	d.exec()
+func (db *DB) CreateInBatches(value any, batchSize int) *Chain {
+	return db.buildChanin().CreateInBatches(value, batchSize)
 }
 
-func (d *Do) prepareCreateSql() {
-	var sqls, columns []string
+func (db *DB) Attrs(values ...any) *Chain {
+	return db.buildChanin().Attrs(values...)
+}
 
-	for key, value := range d.model.columnsAndValues("create") {
-		columns = append(columns, key)
-		sqls = append(sqls, d.addToVars(value))
-	}
+func (db *DB) Assign(values ...any) *Chain {
+	return db.buildChanin().Assign(values...)
+}
 
-	d.sql = fmt.Sprintf(
-		"INSERT INTO %v (%v) VALUES (%v)",
-		d.tableName(),
-		strings.Join(columns, ","),
-		strings.Join(sqls, ","),
-	)
-	return
+func (db *DB) FirstOrInit(out any, where ...any) *Chain {
+	return db.buildChanin().FirstOrInit(out, where...)
 }
 
-func (d *Do) create() {
-	d.prepareCreateSql()
-	if d.hasError() {
-		return
-	}
-	d.exec()
-	if d.hasError() {
-		return
-	}
-	id, err := d.sqlResult.LastInsertId()
-	d.err(err)
-	if d.hasError() {
-		return
-	}
-	result := reflect.ValueOf(d.value).Elem()
-	result.FieldByName(d.model.primaryKey()).SetInt(id)
+func (db *DB) FirstOrCreate(out any, where ...any) (created bool, err error) {
+	return db.buildChanin().FirstOrCreate(out, where...)
 }
 
-func (d *Do) prepareUpdateSql() {
-	updateAttrs := d.model.columnsAndValues("update")
+func (db *DB) Save(value any) *Chain {
+	return db.buildChanin().Save(value)
+}
 
-	var sqls []string
-	for key, value := range updateAttrs {
-		sqls = append(sqls, fmt.Sprintf("%v = %v", key, d.addToVars(value)))
+func (db *DB) Delete(value any) *Chain {
+	return db.buildChanin().Delete(value)
+}
+
+// ScanRows scans the current row of rows into out, mapping columns the
+// same way Find and Scan do:
+//
+//	rows, err := db.Model(&User{}).Where("age > ?", 20).Rows()
+//	defer rows.Close()
+//	for rows.Next() {
+//		var user User
+//		db.ScanRows(rows, &user)
+//	}
+func (db *DB) ScanRows(rows *sql.Rows, out any) error {
+	dest := reflect.ValueOf(out)
+	if dest.Kind() != reflect.Ptr || dest.IsNil() {
+		return errors.New("Destination should be a pointer")
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
 	}
+	fieldIndexes := scanFieldIndexes(dest.Type().Elem(), db.NamingStrategy)
+	return scanRow(rows, columns, fieldIndexes, dest.Elem())
+}
 
-	d.sql = fmt.Sprintf(
-		"UPDATE %v SET %v %v",
-		d.tableName(),
-		strings.Join(sqls, ","),
-		d.combinedSql(),
-	)
+// WithContext returns a handle whose operations run with ctx, so that its
+// cancellation and deadline reach MySQL.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	clone := db.clone()
+	clone.ctx = ctx
+	return clone
 }
 
-func (d *Do) update() {
-	d.prepareUpdateSql()
-	if d.hasError() {
-		return
+// Callback returns the registry of processors run by this handle and the
+// handles derived from it.
+func (db *DB) Callback() *Callback {
+	if db.callbacks == nil {
+		db.callbacks = DefaultCallback.clone()
 	}
-	d.exec()
+	return db.callbacks
 }
 
-func (d *Do) prepareQuerySql() {
-	d.sql = fmt.Sprintf(
-		"SELECT %v FROM %v %v",
-		d.selectSql(),
-		d.tableName(),
-		d.combinedSql(),
-	)
+func (db *DB) buildChanin() *Chain {
+	return &Chain{db: db.db, ctx: db.context(), naming: db.NamingStrategy, callbacks: db.Callback(), savepoints: db.savepoints}
 }
 
-func (d *Do) query(where ...any) {
-
-	if len(where) > 0 {
-		d.where(where[0], where[1:len(where)]...)
+func (db *DB) context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
 	}
+	return db.ctx
+}
 
-	destOut := reflect.Indirect(reflect.ValueOf(d.value))
-	var destType reflect.Type
-	var isSlice bool
-	if x := destOut.Kind(); x == reflect.Slice {
-		destType = destOut.Type().Elem()
-		isSlice = true
-	}
+func (db *DB) clone() *DB {
+	clone := *db
+	return &clone
+}
 
-	d.prepareQuerySql()
-	if d.hasError() {
-		return
+// Begin starts a transaction and returns a handle bound to it. Every
+// operation issued through the returned handle runs inside the transaction
+// until Commit or Rollback is called.
+func (db *DB) Begin() (*DB, error) {
+	if _, ok := db.db.(sqlTx); ok {
+		return nil, errors.New("Transaction already started, use Transaction or SavePoint to nest")
 	}
-
-	rows, err := d.db.Query(d.sql, d.sqlVars...)
-	if d.err(err) != nil {
-		return
+	sdb, ok := db.db.(sqlDb)
+	if !ok {
+		return nil, errors.New("Can't start transaction")
 	}
-	defer rows.Close()
-	if rows.Err() != nil {
-		d.err(rows.Err())
+	stx, err := sdb.BeginTx(db.context(), nil)
+	if err != nil {
+		return nil, err
 	}
+	tx := db.clone()
+	tx.db = stx
+	tx.savepoints = 0
+	return tx, nil
+}
 
-	counts := 0
-	for rows.Next() {
-		counts += 1
-		var dest reflect.Value
-		if isSlice {
-			dest = reflect.New(destType).Elem()
-		} else {
-			dest = reflect.ValueOf(d.value).Elem()
-		}
-		columns, _ := rows.Columns()
-		var values []any
-		for _, value := range columns {
-			field := dest.FieldByName(snakeToUpperCamel(value))
-			if field.IsValid() {
-				values = append(
-					values,
-					field.Addr().Interface(),
-				)
-			}
-		}
-		err := rows.Scan(values...)
-		d.err(err)
-
-		if isSlice {
-			destOut.Set(reflect.Append(destOut, dest))
-		}
-	}
-	if counts == 0 && !isSlice {
-		d.err(errors.New("Record not found!"))
+func (db *DB) Commit() error {
+	if tx, ok := db.db.(sqlTx); ok {
+		return tx.Commit()
 	}
+	return errors.New("Invalid transaction")
 }
 
-func (d *Do) where(queryString any, args ...any) {
-	d.whereClause = append(
-		d.whereClause,
-		map[string]any{
-			"query": queryString,
-			"args":  args,
-		},
-	)
+func (db *DB) Rollback() error {
+	if tx, ok := db.db.(sqlTx); ok {
+		return tx.Rollback()
+	}
+	return errors.New("Invalid transaction")
 }
 
-func (d *Do) hasError() bool {
-	return len(d.Errors) > 0
+func (db *DB) SavePoint(name string) error {
+	_, err := db.db.ExecContext(db.context(), "SAVEPOINT "+name)
+	return err
 }
 
-func (d *Do) setModel(value any) {
-	d.model = &Model{data: value}
-	d.value = value
+func (db *DB) RollbackTo(name string) error {
+	_, err := db.db.ExecContext(db.context(), "ROLLBACK TO SAVEPOINT "+name)
+	return err
 }
 
-func (d *Do) addToVars(value any) string {
-	d.sqlVars = append(d.sqlVars, value)
-	// return fmt.Sprintf("$%d", len(d.sqlVars))
-	return "?"
+func (db *DB) ReleaseSavePoint(name string) error {
+	_, err := db.db.ExecContext(db.context(), "RELEASE SAVEPOINT "+name)
+	return err
 }
 
-func (d *Do) whereSql() (sql string) {
-
-	var primaryCondition string
-	if !d.model.primaryKeyZero() {
-		primaryCondition = d.primaryCondition(d.addToVars(d.model.primaryKeyValue()))
+// Transaction runs fc inside a transaction. The transaction is committed
+// when fc returns nil and rolled back when it returns an error or panics.
+// Called on a handle that is already inside a transaction, fc runs within a
+// savepoint instead, so only the nested work is undone on failure.
+func (db *DB) Transaction(fc func(tx *DB) error) (err error) {
+	if _, ok := db.db.(sqlTx); ok {
+		return db.nestedTransaction(fc)
 	}
 
-	var andConditions, orConditions []string
-	for _, clause := range d.whereClause {
-		andConditions = append(andConditions, d.buildWhereCondition(clause))
+	tx, err := db.Begin()
+	if err != nil {
+		return
 	}
 
-	andSql := strings.Join(andConditions, " AND ")
-	orSql := strings.Join(orConditions, " OR ")
-	combinedConditions := andSql
-	if len(combinedConditions) > 0 {
-		if len(orSql) > 0 {
-			combinedConditions = combinedConditions + " OR " + orSql
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			tx.Rollback()
 		}
-	} else {
-		combinedConditions = orSql
-	}
+	}()
 
-	if len(primaryCondition) > 0 {
-		sql = "WHERE " + primaryCondition
-		if len(combinedConditions) > 0 {
-			sql = sql + " AND ( " + combinedConditions + " )"
-		}
-	} else if len(combinedConditions) > 0 {
-		sql = sql + "WHERE " + combinedConditions
+	err = fc(tx)
+	panicked = false
+	if err == nil {
+		err = tx.Commit()
 	}
 	return
 }
 
-func (d *Do) primaryCondition(value any) string {
-	return fmt.Sprintf("(%v = %v)", d.model.primaryKeyDb(), value)
-}
-
-func (d *Do) buildWhereCondition(clause map[string]any) (str string) {
-	switch clause["query"].(type) {
-	case string:
-		value := clause["query"].(string)
-		str = "( " + value + " )"
-	case int, int32, int64:
-		return d.primaryCondition(d.addToVars(clause["query"]))
+func (db *DB) nestedTransaction(fc func(tx *DB) error) (err error) {
+	tx := db.clone()
+	tx.savepoints++
+	name := fmt.Sprintf("gormysql_sp%d", tx.savepoints)
+	if err = tx.SavePoint(name); err != nil {
+		return
 	}
 
-	args := clause["args"].([]any)
-	for _, arg := range args {
-		d.addToVars(arg)
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			tx.RollbackTo(name)
+		}
+	}()
+
+	err = fc(tx)
+	panicked = false
+	if err == nil {
+		err = tx.ReleaseSavePoint(name)
 	}
 	return
 }
 
-func (d *Do) selectSql() string {
-	return "*"
+//--------- Chain ---------
+
+// WithContext makes the following operations of the chain run with ctx.
+func (c *Chain) WithContext(ctx context.Context) *Chain {
+	c.ctx = ctx
+	return c
 }
 
-func (d *Do) limitSql() string {
-	if len(d.limitStr) == 0 {
-		return ""
-	} else {
-		return " LIMIT " + d.limitStr
-	}
+// Exec runs a raw statement. Values are bound to its "?" placeholders,
+// with slices expanded to one placeholder per element.
+func (c *Chain) Exec(sql string, values ...any) *Chain {
+	do := c.do(nil)
+	do.sql = do.bindVars(sql, values)
+	do.exec()
+	return c
 }
 
-func (d *Do) orderSql() string {
-	if len(d.orderStrs) == 0 {
-		return ""
-	} else {
-		return " ORDER BY " + strings.Join(d.orderStrs, ",")
+func (c *Chain) CreateTable(value any) *Chain {
+	if do := c.do(value).createTable(); !do.hasError() {
+		do.exec()
 	}
+	return c
 }
 
-func (d *Do) combinedSql() string {
-	return d.whereSql() + d.orderSql() + d.limitSql()
+func (c *Chain) Where(queryString any, args ...any) *Chain {
+	c.whereClause = append(c.whereClause, map[string]any{
+		"query": queryString,
+		"args":  args,
+	})
+	return c
 }
 
-func (d *Do) createTable() *Do {
-	var sqls []string
-	for _, field := range d.model.fields("null") {
-		sqls = append(sqls, field.DbName+" "+field.SqlType)
-	}
-	d.sql = fmt.Sprintf(
-		"CREATE TABLE %v (%v)",
-		d.tableName(),
-		strings.Join(sqls, ","),
-	)
-	return d
+// Or adds a condition that matches alternatively to all the conditions
+// added by Where and Not.
+func (c *Chain) Or(queryString any, args ...any) *Chain {
+	c.orClause = append(c.orClause, map[string]any{
+		"query": queryString,
+		"args":  args,
+	})
+	return c
+}
+
+// Not adds a condition that records must not match.
+func (c *Chain) Not(queryString any, args ...any) *Chain {
+	c.notClause = append(c.notClause, map[string]any{
+		"query": queryString,
+		"args":  args,
+	})
+	return c
+}
+
+func (c *Chain) Order(value string) *Chain {
+	c.orderStrs = append(c.orderStrs, value)
+	return c
+}
+
+// Select sets the columns queries return. When saving, only the selected
+// columns are written.
+func (c *Chain) Select(columns ...string) *Chain {
+	c.selectStrs = append(c.selectStrs, columns...)
+	return c
+}
+
+// Omit leaves columns out of queries and of the columns written by Save.
+func (c *Chain) Omit(columns ...string) *Chain {
+	c.omitStrs = append(c.omitStrs, columns...)
+	return c
+}
+
+// Distinct makes queries return distinct rows, of the given columns if any.
+func (c *Chain) Distinct(columns ...string) *Chain {
+	c.distinct = true
+	return c.Select(columns...)
+}
+
+// Limit caps the number of records queried. A negative value removes the
+// limit.
+func (c *Chain) Limit(value int) *Chain {
+	c.limitStr = ""
+	if value >= 0 {
+		c.limitStr = strconv.Itoa(value)
+	}
+	return c
+}
+
+// Offset skips records before the ones queried. A negative value removes
+// the offset.
+func (c *Chain) Offset(value int) *Chain {
+	c.offsetStr = ""
+	if value >= 0 {
+		c.offsetStr = strconv.Itoa(value)
+	}
+	return c
+}
+
+// Joins adds a join clause, like "LEFT JOIN companies ON companies.id =
+// users.company_id", to queries. Joined columns aliased like
+// "company__name" are scanned into the Name field of the Company field.
+func (c *Chain) Joins(query string, args ...any) *Chain {
+	c.joinsClause = append(c.joinsClause, map[string]any{
+		"query": query,
+		"args":  args,
+	})
+	return c
+}
+
+func (c *Chain) Group(value string) *Chain {
+	c.groupStr = value
+	return c
+}
+
+// Having adds a condition on grouped records. It accepts the same forms
+// Where does.
+func (c *Chain) Having(queryString any, args ...any) *Chain {
+	c.havingClause = append(c.havingClause, map[string]any{
+		"query": queryString,
+		"args":  args,
+	})
+	return c
+}
+
+// Raw sets the statement queries of the chain run in place of the one
+// built from its model and conditions. Values are bound like in Exec.
+func (c *Chain) Raw(sql string, values ...any) *Chain {
+	c.rawClause = map[string]any{
+		"query": sql,
+		"args":  values,
+	}
+	return c
+}
+
+// OnConflict sets what the following inserts do with rows colliding with a
+// primary or unique key.
+func (c *Chain) OnConflict(onConflict OnConflict) *Chain {
+	c.onConflict = &onConflict
+	return c
+}
+
+// Unscoped makes the following operations include softly deleted records,
+// and Delete remove records for good.
+func (c *Chain) Unscoped() *Chain {
+	c.unscoped = true
+	return c
+}
+
+// Model sets the model whose table Count, Pluck and the aggregates query.
+func (c *Chain) Model(value any) *Chain {
+	c.model = value
+	return c
+}
+
+// Table sets the name of the table the following operations use in place
+// of the one of their model.
+func (c *Chain) Table(name string) *Chain {
+	c.tableStr = name
+	return c
+}
+
+func (c *Chain) First(out any, where ...any) *Chain {
+	do := c.do(out)
+	do.limitStr = "1"
+	do.inlineWhere(where...)
+	do.callCallbacks(c.callbacks.queries)
+	return c
+}
+
+func (c *Chain) Find(out any, where ...any) *Chain {
+	do := c.do(out)
+	do.inlineWhere(where...)
+	do.callCallbacks(c.callbacks.queries)
+	return c
+}
+
+// Update sets column to value in the matching records, like Updates.
+func (c *Chain) Update(column string, value any) *Chain {
+	return c.Updates(map[string]any{column: value})
+}
+
+// Updates writes the given columns only to the records matching the
+// conditions of the chain and the primary key of its model. values is a map
+// of column or field names to values, or a struct whose zero fields are left
+// out unless selected. It runs the update hooks and touches UpdatedAt; the
+// model gets the values written. Updates with neither conditions nor a
+// primary key fail with ErrMissingWhereClause.
+func (c *Chain) Updates(values any) *Chain {
+	c.updates(values, true)
+	return c
+}
+
+// UpdateColumn is like Update but runs no model hooks and leaves UpdatedAt
+// as is.
+func (c *Chain) UpdateColumn(column string, value any) *Chain {
+	return c.UpdateColumns(map[string]any{column: value})
+}
+
+// UpdateColumns is like Updates but runs no model hooks and leaves
+// UpdatedAt as is. Registered update processors still run.
+func (c *Chain) UpdateColumns(values any) *Chain {
+	c.updates(values, false)
+	return c
+}
+
+func (c *Chain) updates(values any, withHooks bool) {
+	model := c.model
+	if model == nil && isNestedStruct(reflect.TypeOf(values)) {
+		model = values
+		if value := reflect.ValueOf(values); value.Kind() != reflect.Ptr {
+			// a copy that can be given the values written
+			model = reflect.New(value.Type()).Interface()
+			reflect.ValueOf(model).Elem().Set(value)
+		}
+	}
+	do := c.do(model)
+	attrs, err := do.updateAttrsOf(values)
+	if do.err(err) != nil {
+		return
+	}
+	if withHooks {
+		for _, field := range do.model.structFields() {
+			if _, ok := attrs[field.DbName]; field.AutoUpdateTime && !ok {
+				attrs[field.DbName] = time.Now()
+			}
+		}
+	}
+	do.updateAttrs = attrs
+	do.model.assignColumns(attrs)
+	do.skipHooks = !withHooks
+	do.callCallbacks(c.callbacks.updates)
+}
+
+// FindInBatches finds the matching records batchSize at a time into out,
+// which must point to a slice, and calls fc after each batch with a handle
+// running in the same connection or transaction as the chain. Batches are
+// paged by primary key rather than offset, so records are visited in
+// primary key order, before any order of the chain. An Offset skips records
+// before the first batch and a Limit caps the total number of records. It
+// stops at the first error of fc.
+func (c *Chain) FindInBatches(out any, batchSize int, fc func(tx *DB, batch int) error) *Chain {
+	dest := reflect.ValueOf(out)
+	if dest.Kind() != reflect.Ptr || dest.Elem().Kind() != reflect.Slice {
+		c.err(errors.New("Destination should be a pointer to a slice"))
+		return c
+	}
+	if batchSize <= 0 {
+		c.err(errors.New("Batch size should be positive"))
+		return c
+	}
+	dest = dest.Elem()
+	model := &Model{data: out, naming: c.naming}
+	primaryFields := model.primaryFields()
+	if len(primaryFields) == 0 {
+		c.err(errors.New("Primary key is required to find in batches"))
+		return c
+	}
+
+	remaining := -1
+	if len(c.limitStr) > 0 {
+		remaining, _ = strconv.Atoi(c.limitStr)
+	}
+	tx := c.handle()
+	var lastValues []any
+	for batch := 1; remaining != 0; batch++ {
+		limit := batchSize
+		if remaining > 0 && remaining < limit {
+			limit = remaining
+		}
+		dest.Set(reflect.MakeSlice(dest.Type(), 0, limit))
+
+		do := c.do(out)
+		var columns []string
+		for _, field := range primaryFields {
+			columns = append(columns, do.qualifiedColumn(field.DbName))
+		}
+		do.orderStrs = append(append([]string{}, columns...), c.orderStrs...)
+		do.limitStr = strconv.Itoa(limit)
+		if lastValues != nil {
+			// the keyset already starts after the skipped records
+			do.offsetStr = ""
+			placeholders := strings.TrimSuffix(strings.Repeat("?,", len(lastValues)), ",")
+			condition := columns[0] + " > ?"
+			if len(lastValues) > 1 {
+				condition = fmt.Sprintf("(%v) > (%v)", strings.Join(columns, ","), placeholders)
+			}
+			do.scopeClause = append(do.scopeClause, map[string]any{
+				"query": condition,
+				"args":  lastValues,
+			})
+		}
+		do.callCallbacks(c.callbacks.queries)
+		count := dest.Len()
+		if do.hasError() || count == 0 {
+			break
+		}
+
+		last := dest.Index(count - 1)
+		if last.Kind() != reflect.Ptr {
+			last = last.Addr()
+		}
+		lastValues = (&Model{data: last.Interface(), naming: c.naming}).primaryKeyValues()
+		if err := fc(tx, batch); err != nil {
+			c.err(err)
+			break
+		}
+		if count < limit {
+			break
+		}
+		if remaining > 0 {
+			remaining -= count
+		}
+	}
+	return c
+}
+
+// Attrs sets values, maps or structs like the ones of Updates, that
+// FirstOrInit and FirstOrCreate give to the record only when none is found.
+func (c *Chain) Attrs(values ...any) *Chain {
+	c.attrs = append(c.attrs, values...)
+	return c
+}
+
+// Assign sets values, maps or structs like the ones of Updates, that
+// FirstOrInit and FirstOrCreate give to the record whether it's found or
+// not. FirstOrCreate stores them.
+func (c *Chain) Assign(values ...any) *Chain {
+	c.assigns = append(c.assigns, values...)
+	return c
+}
+
+// FirstOrInit finds the first record matching the conditions into out like
+// First. When there is none, out gets the values of the map and struct
+// conditions and of Attrs instead, without being stored. Either way it gets
+// the values of Assign.
+func (c *Chain) FirstOrInit(out any, where ...any) *Chain {
+	c.firstOrInit(out, where)
+	return c
+}
+
+// FirstOrCreate is like FirstOrInit, but creates the record when none is
+// found and stores the values of Assign when one is. It reports whether the
+// record was created. Records without a primary key are updated through the
+// conditions that found them.
+func (c *Chain) FirstOrCreate(out any, where ...any) (created bool, err error) {
+	found, ok := c.firstOrInit(out, where)
+	if !ok {
+		return false, c.Error
+	}
+	if !found {
+		if err := c.Create(out).Error; err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if len(c.assigns) > 0 {
+		attrs, err := c.do(out).assignedAttrs(c.assigns)
+		if err == nil {
+			update := c.handle().Model(out)
+			if (&Model{data: out, naming: c.naming}).primaryKeyZero() {
+				// without a primary key, the record is the one the
+				// conditions found
+				update.whereClause = append([]map[string]any{}, c.whereClause...)
+				update.orClause = c.orClause
+				update.notClause = c.notClause
+				update.unscoped = c.unscoped
+				if len(where) > 0 {
+					update.Where(where[0], where[1:]...)
+				}
+				update.Limit(1)
+			}
+			err = update.Updates(attrs).Error
+		}
+		if c.err(err) != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// firstOrInit runs FirstOrInit and reports whether a record was found, and
+// whether it went without error.
+func (c *Chain) firstOrInit(out any, where []any) (found, ok bool) {
+	dest := reflect.ValueOf(out)
+	if dest.Kind() != reflect.Ptr || dest.Elem().Kind() != reflect.Struct {
+		c.err(errors.New("Destination should be a pointer to a struct"))
+		return false, false
+	}
+
+	// a slice is queried so that no record found isn't an error
+	results := reflect.New(reflect.SliceOf(dest.Elem().Type()))
+	do := c.do(results.Interface())
+	if model := (&Model{data: out, naming: c.naming}); !model.primaryKeyZero() {
+		keys := map[string]any{}
+		for i, field := range model.primaryFields() {
+			keys[field.DbName] = model.primaryKeyValues()[i]
+		}
+		do.scopeClause = append(do.scopeClause, map[string]any{
+			"query": keys,
+			"args":  []any{},
+		})
+	}
+	do.limitStr = "1"
+	do.inlineWhere(where...)
+	do.callCallbacks(c.callbacks.queries)
+	if do.hasError() {
+		return false, false
+	}
+
+	do = c.do(out)
+	var values []any
+	if found = results.Elem().Len() > 0; found {
+		dest.Elem().Set(results.Elem().Index(0))
+	} else {
+		for _, clause := range do.whereClause {
+			values = append(values, clause["query"])
+		}
+		if len(where) > 0 {
+			values = append(values, where[0])
+		}
+		values = append(values, c.attrs...)
+	}
+	values = append(values, c.assigns...)
+	attrs, err := do.assignedAttrs(values)
+	if do.err(err) != nil {
+		return found, false
+	}
+	do.model.assignColumns(attrs)
+	return found, true
+}
+
+// Save creates or updates value depending on its primary key, soft deleted
+// records included. Slices are created, like with Create.
+func (c *Chain) Save(value any) *Chain {
+	if isSliceValue(value) {
+		return c.Create(value)
+	}
+	c.do(value).save()
+	return c
+}
+
+// Create inserts value, which may point to a struct or to a slice of them.
+// Slices are inserted with multi-row statements, as many rows at a time as
+// placeholders allow. Auto increment ids are set back into value.
+func (c *Chain) Create(value any) *Chain {
+	return c.CreateInBatches(value, 0)
+}
+
+// CreateInBatches is like Create but inserts at most batchSize rows per
+// statement. Batches are also split to stay under the placeholder limit and
+// to keep the strings and bytes of a statement under 4MB, the smallest
+// default max_allowed_packet of MySQL; use a smaller batchSize for servers
+// allowing less. Several statements run inside a transaction, or a
+// savepoint when the chain already runs in one, so that they are all undone
+// on error, the ids set into value included.
+func (c *Chain) CreateInBatches(value any, batchSize int) *Chain {
+	if !isSliceValue(value) {
+		c.do(value).callCallbacks(c.callbacks.creates)
+		return c
+	}
+	dest := reflect.ValueOf(value).Elem()
+	if dest.Len() == 0 {
+		return c
+	}
+
+	// MySQL allows up to 65535 placeholders per statement
+	size := 65535
+	model := &Model{data: value, naming: c.naming}
+	fields := model.structFields()
+	if len(fields) > 0 {
+		size = 65535 / len(fields)
+	}
+	if batchSize > 0 && batchSize < size {
+		size = batchSize
+	}
+	var ends []int
+	start, bytes := 0, 0
+	for i := 0; i < dest.Len(); i++ {
+		n := rowBytes(dest.Index(i), fields)
+		if i > start && (i-start == size || bytes+n > maxBatchBytes) {
+			ends = append(ends, i)
+			start, bytes = i, 0
+		}
+		bytes += n
+	}
+	ends = append(ends, dest.Len())
+
+	var batchErr error
+	create := func(db sqlCommon) error {
+		start := 0
+		for _, end := range ends {
+			batch := reflect.New(reflect.SliceOf(dest.Type().Elem()))
+			batch.Elem().Set(dest.Slice(start, end))
+			start = end
+			do := c.do(batch.Interface())
+			do.db = db
+			do.callCallbacks(c.callbacks.creates)
+			if do.hasError() {
+				batchErr = do.Errors[0]
+				return batchErr
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if len(ends) == 1 {
+		err = create(c.db)
+	} else {
+		// ids set into the rows of batches undone on error
+		var ids []reflect.Value
+		if field := model.autoIncrementField(); field != nil {
+			for i := 0; i < dest.Len(); i++ {
+				row := reflect.Indirect(dest.Index(i))
+				if !row.IsValid() {
+					continue
+				}
+				if id, err := row.FieldByIndexErr(field.index); err == nil && id.IsZero() {
+					ids = append(ids, id)
+				}
+			}
+		}
+		err = c.handle().Transaction(func(tx *DB) error {
+			return create(tx.db)
+		})
+		if err != nil {
+			for _, id := range ids {
+				id.Set(reflect.Zero(id.Type()))
+			}
+		}
+	}
+	// errors of the batches are already recorded on the chain
+	if err != nil && !errors.Is(err, batchErr) {
+		c.err(err)
+	}
+	return c
+}
+
+// Delete deletes value, and the records matching the conditions of the
+// chain. Models with a DeletedAt field are only marked deleted, unless
+// Unscoped. Without conditions or a primary key it fails with
+// ErrMissingWhereClause.
+func (c *Chain) Delete(value any) *Chain {
+	c.do(value).callCallbacks(c.callbacks.deletes)
+	return c
+}
+
+// Scan runs the query of the chain and stores its rows into out, which can
+// point to any struct, a map[string]any, a scalar, or a slice of them.
+// Columns without a matching destination are discarded.
+func (c *Chain) Scan(out any) *Chain {
+	model := c.model
+	if model == nil {
+		model = out
+	}
+	c.do(model).scanQuery(out)
+	return c
+}
+
+// Rows runs the query of the chain and returns its rows, to be read one at
+// a time with ScanRows. The caller must close them.
+func (c *Chain) Rows() (*sql.Rows, error) {
+	return c.do(c.model).queryRows()
+}
+
+// Restore undoes the soft deletion of value, and of the records matching the
+// conditions of the chain. Like Delete, it needs one or the other.
+func (c *Chain) Restore(value any) *Chain {
+	c.do(value).restore()
+	return c
+}
+
+// Count stores the number of records matching the conditions of the chain
+// into out, which must point to an integer.
+func (c *Chain) Count(out any) *Chain {
+	c.do(c.model).count(out)
+	return c
+}
+
+// Pluck stores the values of column of the matching records into out, which
+// must point to a slice.
+func (c *Chain) Pluck(column string, out any) *Chain {
+	c.do(c.model).pluck(column, out)
+	return c
+}
+
+// Sum stores the sum of column over the matching records into out.
+func (c *Chain) Sum(column string, out any) *Chain {
+	c.do(c.model).aggregate("SUM", column, out)
+	return c
+}
+
+// Avg stores the average of column over the matching records into out.
+func (c *Chain) Avg(column string, out any) *Chain {
+	c.do(c.model).aggregate("AVG", column, out)
+	return c
+}
+
+// Min stores the smallest value of column among the matching records into
+// out.
+func (c *Chain) Min(column string, out any) *Chain {
+	c.do(c.model).aggregate("MIN", column, out)
+	return c
+}
+
+// Max stores the largest value of column among the matching records into
+// out.
+func (c *Chain) Max(column string, out any) *Chain {
+	c.do(c.model).aggregate("MAX", column, out)
+	return c
+}
+
+func (c *Chain) do(value any) *Do {
+	var do Do
+	do.db = c.db
+	do.ctx = c.ctx
+	do.chain = c
+	do.whereClause = c.whereClause
+	do.orClause = c.orClause
+	do.notClause = c.notClause
+	do.havingClause = c.havingClause
+	do.joinsClause = c.joinsClause
+	do.rawClause = c.rawClause
+	do.orderStrs = c.orderStrs
+	do.selectStrs = c.selectStrs
+	do.omitStrs = c.omitStrs
+	do.groupStr = c.groupStr
+	do.limitStr = c.limitStr
+	do.offsetStr = c.offsetStr
+	do.distinct = c.distinct
+	do.tableStr = c.tableStr
+	do.onConflict = c.onConflict
+	do.unscoped = c.unscoped
+
+	c.value = value
+	do.setModel(value)
+	return &do
+}
+
+// handle returns a DB running in the same connection or transaction as the
+// chain.
+func (c *Chain) handle() *DB {
+	return &DB{NamingStrategy: c.naming, db: c.db, ctx: c.ctx, callbacks: c.callbacks, savepoints: c.savepoints}
+}
+
+func (c *Chain) err(err error) error {
+	if err != nil {
+		c.Errors = append(c.Errors, err)
+		c.Error = err
+	}
+	return err
+}
+
+//--------- Do ---------
+
+func (d *Do) exec() {
+	var err error
+	d.sqlResult, err = d.db.ExecContext(d.ctx, d.sql, d.sqlVars...)
+	if d.dbErr(err) == nil {
+		d.chain.RowsAffected = d.RowsAffected()
+	}
+}
+
+func (d *Do) prepareDeleteSql() {
+	d.sql = fmt.Sprintf(
+		"DELETE FROM %v %v",
+		d.tableName(),
+		d.writeSql(),
+	)
+}
+
+// checkValue records an error unless the value written is a pointer to a
+// struct or to a slice. Copies can't be keyed by their primary key nor get
+// their ids back.
+func (d *Do) checkValue() bool {
+	value := reflect.ValueOf(d.model.data)
+	if d.model.data == nil || (value.Kind() == reflect.Ptr && !value.IsNil() &&
+		(value.Elem().Kind() == reflect.Struct || value.Elem().Kind() == reflect.Slice)) {
+		// a missing model is reported with the table name
+		return true
+	}
+	d.err(fmt.Errorf("Value should be a pointer to a struct, got %T", d.model.data))
+	return false
+}
+
+// hasConditions records ErrMissingWhereClause unless the statement is
+// limited by conditions or the model's primary key, so that writes never
+// reach every record by mistake.
+func (d *Do) hasConditions() bool {
+	if d.model.primaryKeyZero() && len(d.whereClause)+len(d.orClause)+len(d.notClause)+len(d.scopeClause) == 0 {
+		d.err(ErrMissingWhereClause)
+		return false
+	}
+	return true
+}
+
+// save creates the record, or updates the one with its primary key. The key
+// pins the record, so soft deleted ones are updated rather than created
+// again.
+func (d *Do) save() {
+	if !d.checkValue() {
+		return
+	}
+	if d.model.primaryKeyZero() {
+		d.callCallbacks(d.chain.callbacks.creates)
+		return
+	}
+	d.unscoped = true
+	if d.model.autoIncrementField() != nil || d.recordExists() {
+		d.callCallbacks(d.chain.callbacks.updates)
+	} else if !d.hasError() {
+		d.callCallbacks(d.chain.callbacks.creates)
+	}
+}
+
+// recordExists reports whether a row with the model's primary key is
+// already stored, soft deleted or not. It is used to tell inserts from
+// updates for models whose primary key is assigned by the application.
+func (d *Do) recordExists() bool {
+	d.sql = fmt.Sprintf("SELECT 1 FROM %v %v LIMIT 1", d.tableName(), d.whereSql())
+	if d.hasError() {
+		return false
+	}
+	rows, err := d.db.QueryContext(d.ctx, d.sql, d.sqlVars...)
+	d.sql, d.sqlVars = "", nil
+	if d.dbErr(err) != nil {
+		return false
+	}
+	defer rows.Close()
+	exists := rows.Next()
+	d.dbErr(rows.Err())
+	return exists
+}
+
+func (d *Do) delete() {
+	if !d.checkValue() || !d.hasConditions() {
+		return
+	}
+	if field := d.model.softDeleteField(); field != nil && !d.unscoped {
+		d.softDelete(field)
+		return
+	}
+	d.prepareDeleteSql()
+	if d.hasError() {
+		return
+	}
+	d.exec()
+}
+
+// softDelete marks the records deleted by setting their DeletedAt field,
+// which queries then leave them out by.
+func (d *Do) softDelete(field *Field) {
+	now := time.Now()
+	d.sql = fmt.Sprintf(
+		"UPDATE %v SET %v = %v %v",
+		d.tableName(),
+		field.DbName,
+		d.addToVars(now),
+		d.writeSql(),
+	)
+	if d.hasError() {
+		return
+	}
+	d.exec()
+	if value, ok := d.model.fieldValue(field); ok && !d.hasError() {
+		setTime(value, now)
+	}
+}
+
+// restore clears the DeletedAt field of softly deleted records.
+func (d *Do) restore() {
+	if !d.checkValue() || !d.hasConditions() {
+		return
+	}
+	field := d.model.softDeleteField()
+	if field == nil {
+		d.err(fmt.Errorf("%v has no DeletedAt field to restore", d.model.structType()))
+		return
+	}
+	d.unscoped = true
+	d.sql = fmt.Sprintf(
+		"UPDATE %v SET %v = NULL %v",
+		d.tableName(),
+		field.DbName,
+		d.writeSql(),
+	)
+	if d.hasError() {
+		return
+	}
+	d.exec()
+	if value, ok := d.model.fieldValue(field); ok && !d.hasError() {
+		value.Set(reflect.Zero(value.Type()))
+	}
+}
+
+func (d *Do) prepareCreateSql() {
+	var rows []map[string]any
+	columnSet := map[string]bool{}
+	for _, model := range d.model.elements() {
+		row := model.columnsAndValues("create")
+		for column := range row {
+			if d.columnSelected(column) {
+				columnSet[column] = true
+			}
+		}
+		rows = append(rows, row)
+	}
+	var columns []string
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	// rows lacking a column, like an auto increment key or a column with a
+	// default left zero, get its default
+	var values []string
+	for _, row := range rows {
+		var sqls []string
+		for _, column := range columns {
+			if value, ok := row[column]; ok {
+				sqls = append(sqls, d.addToVars(value))
+			} else {
+				sqls = append(sqls, "DEFAULT")
+			}
+		}
+		values = append(values, "("+strings.Join(sqls, ",")+")")
+	}
+
+	insert := "INSERT"
+	if d.onConflict != nil && d.onConflict.DoNothing {
+		insert = "INSERT IGNORE"
+	}
+	d.sql = fmt.Sprintf(
+		"%v INTO %v (%v) VALUES %v%v",
+		insert,
+		d.tableName(),
+		strings.Join(columns, ","),
+		strings.Join(values, ","),
+		d.onConflictSql(columns),
+	)
+	return
+}
+
+func (d *Do) create() {
+	if !d.checkValue() {
+		return
+	}
+	d.prepareCreateSql()
+	if d.hasError() {
+		return
+	}
+	d.exec()
+	if d.hasError() {
+		return
+	}
+	d.setAutoIncrementId()
+}
+
+// setAutoIncrementId sets the ids generated by the insert into the models
+// lacking one. Rows inserted by one statement get consecutive ids starting
+// from the last insert id, which only holds when every row was inserted
+// with a generated id; explicit ids move the counter.
+func (d *Do) setAutoIncrementId() {
+	field := d.model.autoIncrementField()
+	if field == nil {
+		return
+	}
+	models := d.model.elements()
+	if len(models) > 1 {
+		// updated rows count twice, so counts can't tell rows apart
+		if d.RowsAffected() != int64(len(models)) || (d.onConflict != nil && !d.onConflict.DoNothing) {
+			return
+		}
+		for _, model := range models {
+			if value, err := reflect.ValueOf(model.data).Elem().FieldByIndexErr(field.index); err == nil && !value.IsZero() {
+				return
+			}
+		}
+	}
+	id, err := d.sqlResult.LastInsertId()
+	if d.err(err) != nil {
+		return
+	}
+	for _, model := range models {
+		value := fieldByIndexAlloc(reflect.ValueOf(model.data).Elem(), field.index)
+		if !value.IsZero() {
+			continue
+		}
+		if value.Kind() == reflect.Ptr {
+			value.Set(reflect.New(value.Type().Elem()))
+			value = value.Elem()
+		}
+		switch {
+		case value.CanInt():
+			value.SetInt(id)
+		case value.CanUint():
+			value.SetUint(uint64(id))
+		default:
+			d.err(fmt.Errorf("Can't set auto increment id to field %v of type %v", field.Name, value.Type()))
+			return
+		}
+		id++
+	}
+}
+
+func (d *Do) prepareUpdateSql() {
+	updateAttrs := d.updateAttrs
+	if updateAttrs == nil {
+		updateAttrs = d.model.columnsAndValues("update")
+	}
+
+	var columns []string
+	for column := range updateAttrs {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var sqls []string
+	for _, column := range columns {
+		if !d.columnSelected(column) {
+			continue
+		}
+		sqls = append(sqls, fmt.Sprintf("%v = %v", column, d.addToVars(updateAttrs[column])))
+	}
+	if len(sqls) == 0 {
+		// nothing to write
+		d.sql, d.sqlVars = "", nil
+		return
+	}
+
+	d.sql = fmt.Sprintf(
+		"UPDATE %v SET %v %v",
+		d.tableName(),
+		strings.Join(sqls, ","),
+		d.writeSql(),
+	)
+}
+
+func (d *Do) update() {
+	if !d.checkValue() {
+		return
+	}
+	if !d.hasConditions() {
+		return
+	}
+	d.prepareUpdateSql()
+	if d.hasError() || len(d.sql) == 0 {
+		return
+	}
+	d.exec()
+}
+
+// assignedAttrs merges the columns of the map and struct values, later ones
+// taking precedence. Other values, like string conditions, are skipped.
+func (d *Do) assignedAttrs(values []any) (map[string]any, error) {
+	attrs := map[string]any{}
+	for _, value := range values {
+		switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+		case reflect.Map, reflect.Struct:
+			columns, err := d.updateAttrsOf(value)
+			if err != nil {
+				return nil, err
+			}
+			for column, v := range columns {
+				attrs[column] = v
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// updateAttrsOf maps the values passed to Updates to columns. Maps may be
+// keyed by column or field name; structs give their non-zero fields, or the
+// selected ones, primary keys aside.
+func (d *Do) updateAttrsOf(values any) (map[string]any, error) {
+	attrs := map[string]any{}
+	value := reflect.Indirect(reflect.ValueOf(values))
+	switch value.Kind() {
+	case reflect.Map:
+		columns := map[string]string{}
+		for column, name := range d.model.columnFieldNames() {
+			columns[name] = column
+		}
+		for _, key := range value.MapKeys() {
+			column := fmt.Sprint(key.Interface())
+			if c, ok := columns[column]; ok {
+				column = c
+			}
+			attrs[column] = value.MapIndex(key).Interface()
+		}
+	case reflect.Struct:
+		model := &Model{data: values, naming: d.chain.naming}
+		for _, field := range model.structFields() {
+			fieldValue, err := value.FieldByIndexErr(field.index)
+			if err != nil || field.IsPrimaryKey {
+				continue
+			}
+			if fieldValue.IsZero() && (len(d.selectStrs) == 0 || !d.columnSelected(field.DbName)) {
+				continue
+			}
+			attrs[field.DbName] = fieldValue.Interface()
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported update values %T", values)
+	}
+	return attrs, nil
+}
+
+func (d *Do) prepareQuerySql() {
+	if d.rawClause != nil {
+		d.sql = d.bindVars(d.rawClause["query"].(string), d.rawClause["args"].([]any))
+	} else {
+		d.sql = fmt.Sprintf(
+			"SELECT %v FROM %v%v %v",
+			d.selectSql(),
+			d.tableName(),
+			d.joinsSql(),
+			d.combinedSql(),
+		)
+	}
+	if d.countSubquery {
+		d.sql = fmt.Sprintf("SELECT count(*) FROM (%v) AS count_table", d.sql)
+	}
+}
+
+// query runs the query and loads its rows into the value, or into scanOut
+// or sqlRows when set.
+func (d *Do) query() {
+	d.prepareQuerySql()
+	if d.hasError() {
+		return
+	}
+	if d.keepRows {
+		d.sqlRows, _ = d.rows()
+		return
+	}
+	if d.scanOut != nil {
+		d.scan(d.scanOut)
+		return
+	}
+
+	destOut := reflect.Indirect(reflect.ValueOf(d.value))
+	var destType reflect.Type
+	var isSlice bool
+	if x := destOut.Kind(); x == reflect.Slice {
+		destType = destOut.Type().Elem()
+		isSlice = true
+	}
+
+	rows, err := d.db.QueryContext(d.ctx, d.sql, d.sqlVars...)
+	if d.dbErr(err) != nil {
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if d.dbErr(err) != nil {
+		return
+	}
+	fieldIndexes := d.model.columnFieldIndexes()
+	counts := 0
+	for rows.Next() {
+		counts += 1
+		var dest reflect.Value
+		if isSlice {
+			dest = reflect.New(destType).Elem()
+		} else {
+			dest = reflect.ValueOf(d.value).Elem()
+		}
+		if d.err(scanRow(rows, columns, fieldIndexes, dest)) != nil {
+			return
+		}
+
+		if isSlice {
+			destOut.Set(reflect.Append(destOut, dest))
+		}
+	}
+	if d.dbErr(rows.Err()) != nil {
+		return
+	}
+	d.chain.RowsAffected = int64(counts)
+	if counts == 0 && !isSlice {
+		d.err(ErrRecordNotFound)
+	}
+}
+
+// count queries the number of matching records. Grouped, distinct or
+// limited queries are counted through a subquery since their rows aren't
+// the records of the table.
+func (d *Do) count(out any) {
+	if len(d.groupStr) > 0 || d.distinct || len(d.limitStr) > 0 || len(d.offsetStr) > 0 {
+		d.countSubquery = true
+	} else {
+		d.selectStrs = []string{"count(*)"}
+		d.orderStrs = nil
+	}
+	d.scanQuery(out)
+}
+
+func (d *Do) pluck(column string, out any) {
+	d.selectStrs = []string{column}
+	d.scanQuery(out)
+}
+
+func (d *Do) aggregate(fn, column string, out any) {
+	d.selectStrs = []string{fmt.Sprintf("%v(%v)", fn, column)}
+	d.distinct = false
+	d.scanQuery(out)
+}
+
+// scanQuery runs the query processors with the rows scanned into out in
+// place of the value.
+func (d *Do) scanQuery(out any) {
+	d.scanOut = out
+	d.callCallbacks(d.chain.callbacks.queries)
+}
+
+// queryRows runs the query processors and returns the rows, left open.
+func (d *Do) queryRows() (*sql.Rows, error) {
+	d.keepRows = true
+	d.callCallbacks(d.chain.callbacks.queries)
+	if d.hasError() {
+		if d.sqlRows != nil {
+			d.sqlRows.Close()
+		}
+		return nil, d.Errors[0]
+	}
+	return d.sqlRows, nil
+}
+
+// scan runs the query and scans its rows into out.
+func (d *Do) scan(out any) {
+	rows, err := d.rows()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	if d.err(scanRows(rows, out, d.chain.naming)) == nil {
+		d.dbErr(rows.Err())
+	}
+}
+
+// rows runs the query without reading its rows.
+func (d *Do) rows() (*sql.Rows, error) {
+	if d.hasError() {
+		return nil, d.Errors[0]
+	}
+	rows, err := d.db.QueryContext(d.ctx, d.sql, d.sqlVars...)
+	return rows, d.dbErr(err)
+}
+
+// scanRows scans rows into out, which must be a pointer to a struct, a
+// map[string]any, a scalar, or a slice of any of them. A slice gets one
+// element per row, anything else only the first row.
+func scanRows(rows *sql.Rows, out any, naming NamingStrategy) error {
+	dest := reflect.ValueOf(out)
+	if dest.Kind() != reflect.Ptr || dest.IsNil() {
+		return errors.New("Destination should be a pointer")
+	}
+	dest = dest.Elem()
+	isSlice := dest.Kind() == reflect.Slice && dest.Type().Elem().Kind() != reflect.Uint8
+	elemType := dest.Type()
+	if isSlice {
+		elemType = elemType.Elem()
+		dest.Set(reflect.MakeSlice(dest.Type(), 0, 0))
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	fieldIndexes := scanFieldIndexes(elemType, naming)
+	for rows.Next() {
+		elem := dest
+		if isSlice {
+			elem = reflect.New(elemType).Elem()
+		}
+		if err := scanRow(rows, columns, fieldIndexes, elem); err != nil {
+			return err
+		}
+
+		if !isSlice {
+			break
+		}
+		dest.Set(reflect.Append(dest, elem))
+	}
+	return nil
+}
+
+// scanFieldIndexes maps the columns to the fields of t when it's a struct,
+// or a pointer to one, and returns nil otherwise.
+func scanFieldIndexes(t reflect.Type, naming NamingStrategy) map[string][]int {
+	if !isNestedStruct(t) {
+		return nil
+	}
+	model := &Model{data: reflect.New(indirectType(t)).Interface(), naming: naming}
+	return model.columnFieldIndexes()
+}
+
+// scanRow scans the current row into dest, allocating it if it's a nil
+// pointer. Structs get the columns found in fieldIndexes, maps every column
+// and scalars the first one; other columns are discarded. NULL is stored as
+// the zero value.
+func scanRow(rows *sql.Rows, columns []string, fieldIndexes map[string][]int, dest reflect.Value) error {
+	for dest.Kind() == reflect.Ptr {
+		if dest.IsNil() {
+			dest.Set(reflect.New(dest.Type().Elem()))
+		}
+		dest = dest.Elem()
+	}
+
+	switch {
+	case fieldIndexes != nil:
+		return scanStruct(rows, columns, fieldIndexes, dest)
+	case dest.Kind() == reflect.Map:
+		if dest.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("Unsupported destination %v", dest.Type())
+		}
+		return scanMap(rows, columns, dest)
+	default:
+		return scanValue(rows, columns, dest)
+	}
+}
+
+// scanValue scans the first column of the current row into dest.
+func scanValue(rows *sql.Rows, columns []string, dest reflect.Value) error {
+	holder := reflect.New(reflect.PointerTo(dest.Type()))
+	values := make([]any, len(columns))
+	for i := range values {
+		values[i] = new(any)
+	}
+	values[0] = holder.Interface()
+	if err := rows.Scan(values...); err != nil {
+		return err
+	}
+	if holder.Elem().IsNil() {
+		dest.Set(reflect.Zero(dest.Type()))
+	} else {
+		dest.Set(holder.Elem().Elem())
+	}
+	return nil
+}
+
+// scanMap stores every column of the current row into dest, allocating the
+// map if needed. Bytes are stored as strings.
+func scanMap(rows *sql.Rows, columns []string, dest reflect.Value) error {
+	values := make([]any, len(columns))
+	for i := range values {
+		values[i] = new(any)
+	}
+	if err := rows.Scan(values...); err != nil {
+		return err
+	}
+	if dest.IsNil() {
+		dest.Set(reflect.MakeMap(dest.Type()))
+	}
+	elemType := dest.Type().Elem()
+	for i, column := range columns {
+		value := *(values[i].(*any))
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		v := reflect.Zero(elemType)
+		if value != nil {
+			v = reflect.ValueOf(value)
+			if !v.Type().AssignableTo(elemType) {
+				if !v.Type().ConvertibleTo(elemType) {
+					return fmt.Errorf("Can't store column %v of type %T into %v", column, value, elemType)
+				}
+				v = v.Convert(elemType)
+			}
+		}
+		dest.SetMapIndex(reflect.ValueOf(column).Convert(dest.Type().Key()), v)
+	}
+	return nil
+}
+
+// scanStruct scans the current row into the fields of dest found in
+// fieldIndexes. Other columns are discarded. NULL leaves a field zero, and
+// pointers to nested structs are only allocated when one of their columns
+// isn't NULL.
+func scanStruct(rows *sql.Rows, columns []string, fieldIndexes map[string][]int, dest reflect.Value) error {
+	values := make([]any, len(columns))
+	indexes := make([][]int, len(columns))
+	for i, column := range columns {
+		if index, ok := fieldIndexes[column]; ok {
+			fieldType := dest.Type().FieldByIndex(index).Type
+			values[i] = reflect.New(reflect.PointerTo(fieldType)).Interface()
+			indexes[i] = index
+		} else {
+			values[i] = new(any)
+		}
+	}
+	if err := rows.Scan(values...); err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		if indexes[i] == nil {
+			continue
+		}
+		holder := reflect.ValueOf(value).Elem()
+		if !holder.IsNil() {
+			fieldByIndexAlloc(dest, indexes[i]).Set(holder.Elem())
+		} else if field, err := dest.FieldByIndexErr(indexes[i]); err == nil {
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+	return nil
+}
+
+// inlineWhere adds the conditions passed inline to First and Find.
+func (d *Do) inlineWhere(where ...any) {
+	if len(where) > 0 {
+		d.Where(where[0], where[1:]...)
+	}
+}
+
+// Where adds a condition to the operation only, leaving its chain as is.
+// Or conditions of the chain may still match other records; see Scope.
+func (d *Do) Where(queryString any, args ...any) {
+	d.whereClause = append(
+		d.whereClause,
+		map[string]any{
+			"query": queryString,
+			"args":  args,
+		},
+	)
+}
+
+// Scope adds a condition to the operation only, ANDed with all the others,
+// Or conditions included, so that it can't be bypassed. Processors use it
+// to restrict every statement, e.g. to the rows of one tenant.
+func (d *Do) Scope(queryString any, args ...any) {
+	d.scopeClause = append(
+		d.scopeClause,
+		map[string]any{
+			"query": queryString,
+			"args":  args,
+		},
+	)
+}
+
+func (d *Do) hasError() bool {
+	return len(d.Errors) > 0
+}
+
+func (d *Do) setModel(value any) {
+	d.model = &Model{data: value, naming: d.chain.naming}
+	d.value = value
+}
+
+// addToVars binds value and returns its placeholder. Slices are expanded
+// into one placeholder per element, and an empty slice into an empty
+// subquery so that `IN (?)` matches nothing and `NOT IN (?)` everything;
+// NULL would make both match nothing.
+const emptySubquery = "SELECT NULL FROM DUAL WHERE FALSE"
+
+func (d *Do) addToVars(value any) string {
+	if values, ok := expandSlice(value); ok {
+		if len(values) == 0 {
+			return emptySubquery
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			placeholders[i] = d.addToVars(v)
+		}
+		return strings.Join(placeholders, ",")
+	}
+	if expr, ok := value.(SqlExpr); ok {
+		return d.bindVars(expr.sql, expr.vars)
+	}
+	d.sqlVars = append(d.sqlVars, value)
+	// return fmt.Sprintf("$%d", len(d.sqlVars))
+	return "?"
+}
+
+// bindVars binds args to the placeholders of query and returns the query
+// with the placeholders addToVars produced. Positional args bind to "?" in
+// order. sql.NamedArg values, or a single map or struct arg, bind to @name
+// placeholders, where struct fields are found by field or column name.
+// Placeholders inside quoted strings and identifiers are left alone.
+func (d *Do) bindVars(query string, args []any) string {
+	args, named := d.namedVars(query, args)
+
+	var buf strings.Builder
+	var quote rune
+	escaped := false
+	i := 0
+	runes := []rune(query)
+	for j := 0; j < len(runes); j++ {
+		r := runes[j]
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote != '`' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '?' && i < len(args):
+			buf.WriteString(d.bindVar(buf.String(), args[i]))
+			i++
+			continue
+		case r == '@' && len(named) > 0:
+			if j+1 < len(runes) && runes[j+1] == '@' {
+				// system variables like @@sql_mode
+				buf.WriteString("@@")
+				j++
+				continue
+			}
+			end := j + 1
+			for end < len(runes) && isIdentRune(runes[end], end == j+1) {
+				end++
+			}
+			if value, ok := named[string(runes[j+1:end])]; ok {
+				buf.WriteString(d.bindVar(buf.String(), value))
+				j = end - 1
+				continue
+			}
+		}
+		buf.WriteRune(r)
+	}
+	for ; i < len(args); i++ {
+		d.addToVars(args[i])
+	}
+	return buf.String()
+}
+
+// bindVar binds value to a placeholder following sql. Slices bound right
+// after IN are wrapped in parentheses, so that `IN ?` works like `IN (?)`.
+func (d *Do) bindVar(sql string, value any) string {
+	placeholder := d.addToVars(value)
+	if _, ok := expandSlice(value); ok {
+		sql = strings.TrimRightFunc(sql, unicode.IsSpace)
+		if len(sql) >= 2 && strings.EqualFold(sql[len(sql)-2:], "IN") &&
+			(len(sql) == 2 || !isIdentRune(rune(sql[len(sql)-3]), false)) {
+			placeholder = "(" + placeholder + ")"
+		}
+	}
+	return placeholder
+}
+
+// namedVars splits args into positional args and the values of named
+// placeholders.
+func (d *Do) namedVars(query string, args []any) (positional []any, named map[string]any) {
+	if !strings.Contains(query, "@") {
+		return args, nil
+	}
+
+	named = map[string]any{}
+	if len(args) == 1 {
+		value := reflect.Indirect(reflect.ValueOf(args[0]))
+		_, isValuer := args[0].(driver.Valuer)
+		_, isNamedArg := args[0].(sql.NamedArg)
+		switch {
+		case isValuer || isNamedArg || !value.IsValid() || value.Type() == reflect.TypeOf(time.Time{}):
+		case value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String:
+			for _, key := range value.MapKeys() {
+				named[key.String()] = value.MapIndex(key).Interface()
+			}
+			return nil, named
+		case value.Kind() == reflect.Struct:
+			model := &Model{data: reflect.New(value.Type()).Interface(), naming: d.model.naming}
+			for _, field := range model.structFields() {
+				// fields behind a nil embedded pointer are NULL
+				named[field.Name] = nil
+				if fieldValue, err := value.FieldByIndexErr(field.index); err == nil {
+					named[field.Name] = fieldValue.Interface()
+				}
+				named[field.DbName] = named[field.Name]
+			}
+			return nil, named
+		}
+	}
+
+	for _, arg := range args {
+		if namedArg, ok := arg.(sql.NamedArg); ok {
+			named[namedArg.Name] = namedArg.Value
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	return
+}
+
+func (d *Do) whereSql() (sql string) {
+
+	var primaryConditions []string
+	if !d.model.primaryKeyZero() {
+		primaryConditions = append(primaryConditions, d.primaryCondition(d.model.primaryKeyValues()...))
+	}
+	for _, clause := range d.scopeClause {
+		if condition := d.buildWhereCondition(clause); len(condition) > 0 {
+			primaryConditions = append(primaryConditions, condition)
+		}
+	}
+	if field := d.model.softDeleteField(); field != nil && !d.unscoped {
+		primaryConditions = append(primaryConditions, d.qualifiedColumn(field.DbName)+" IS NULL")
+	}
+
+	var andConditions, orConditions []string
+	for _, clause := range d.whereClause {
+		if condition := d.buildWhereCondition(clause); len(condition) > 0 {
+			andConditions = append(andConditions, condition)
+		}
+	}
+	for _, clause := range d.notClause {
+		if condition := d.buildWhereCondition(clause); len(condition) > 0 {
+			andConditions = append(andConditions, "NOT "+condition)
+		}
+	}
+	for _, clause := range d.orClause {
+		if condition := d.buildWhereCondition(clause); len(condition) > 0 {
+			orConditions = append(orConditions, condition)
+		}
+	}
+
+	andSql := strings.Join(andConditions, " AND ")
+	orSql := strings.Join(orConditions, " OR ")
+	combinedConditions := andSql
+	if len(combinedConditions) > 0 {
+		if len(orSql) > 0 {
+			if len(andConditions) > 1 {
+				combinedConditions = "( " + combinedConditions + " )"
+			}
+			combinedConditions = combinedConditions + " OR " + orSql
+		}
+	} else {
+		combinedConditions = orSql
+	}
+
+	if len(primaryConditions) > 0 {
+		sql = "WHERE " + strings.Join(primaryConditions, " AND ")
+		if len(combinedConditions) > 0 {
+			sql = sql + " AND ( " + combinedConditions + " )"
+		}
+	} else if len(combinedConditions) > 0 {
+		sql = sql + "WHERE " + combinedConditions
+	}
+	return
+}
+
+// primaryCondition matches the primary key columns against values, in the
+// order the keys are declared.
+func (d *Do) primaryCondition(values ...any) string {
+	var conditions []string
+	for i, field := range d.model.primaryFields() {
+		if i >= len(values) {
+			break
+		}
+		conditions = append(conditions, d.columnCondition(d.qualifiedColumn(field.DbName), values[i]))
+	}
+	if len(conditions) == 0 {
+		d.err(fmt.Errorf("Model %v has no primary key", d.model.structType()))
+		return ""
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+func (d *Do) buildWhereCondition(clause map[string]any) (str string) {
+	args := clause["args"].([]any)
+	switch value := clause["query"].(type) {
+	case string:
+		if len(args) == 1 && isColumnName(value) {
+			// a bare column, like Not("id", ids), matches the arg
+			return "( " + d.columnCondition(d.qualifiedColumn(value), args[0]) + " )"
+		}
+		return "( " + d.bindVars(value, args) + " )"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return d.primaryCondition(value)
+	case nil:
+	default:
+		query := reflect.Indirect(reflect.ValueOf(value))
+		switch {
+		case query.Kind() == reflect.Map && query.Type().Key().Kind() == reflect.String:
+			return d.mapCondition(query)
+		case query.Kind() == reflect.Struct:
+			return d.structCondition(query)
+		case query.Kind() == reflect.Slice || query.Kind() == reflect.Array:
+			return d.primaryCondition(value)
+		}
+		d.err(fmt.Errorf("Unsupported condition type %T", value))
+		return
+	}
+
+	for _, arg := range args {
+		d.addToVars(arg)
+	}
+	return
+}
+
+// mapCondition matches every column of the map against its value, where a
+// nil value matches NULL.
+func (d *Do) mapCondition(query reflect.Value) string {
+	var keys []string
+	for _, key := range query.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	var sqls []string
+	for _, key := range keys {
+		value := query.MapIndex(reflect.ValueOf(key).Convert(query.Type().Key())).Interface()
+		sqls = append(sqls, d.columnCondition(d.qualifiedColumn(key), value))
+	}
+	if len(sqls) == 0 {
+		return ""
+	}
+	return "( " + strings.Join(sqls, " AND ") + " )"
+}
+
+// structCondition matches the columns of the struct's non-zero fields
+// against their values.
+func (d *Do) structCondition(query reflect.Value) string {
+	model := &Model{data: reflect.New(query.Type()).Interface(), naming: d.model.naming}
+
+	var sqls []string
+	for _, field := range model.structFields() {
+		value, err := query.FieldByIndexErr(field.index)
+		if err != nil || value.IsZero() {
+			continue
+		}
+		sqls = append(sqls, d.columnCondition(d.qualifiedColumn(field.DbName), value.Interface()))
+	}
+	if len(sqls) == 0 {
+		return ""
+	}
+	return "( " + strings.Join(sqls, " AND ") + " )"
+}
+
+func (d *Do) columnCondition(column string, value any) string {
+	if value == nil {
+		return fmt.Sprintf("(%v IS NULL)", column)
+	}
+	if _, ok := expandSlice(value); ok {
+		return fmt.Sprintf("(%v IN (%v))", column, d.addToVars(value))
+	}
+	return fmt.Sprintf("(%v = %v)", column, d.addToVars(value))
+}
+
+// columnSelected reports whether Select and Omit let the column be
+// written. Both accept column or field names.
+func (d *Do) columnSelected(column string) bool {
+	fieldNames := d.model.columnFieldNames()
+	matches := func(names []string) bool {
+		for _, name := range names {
+			for _, str := range strings.Split(name, ",") {
+				str = strings.TrimSpace(str)
+				if str == "*" || str == column || str == fieldNames[column] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if len(d.selectStrs) > 0 && !matches(d.selectStrs) {
+		return false
+	}
+	return !matches(d.omitStrs)
+}
+
+func (d *Do) selectSql() string {
+	columns := d.selectStrs
+	if len(columns) == 0 && len(d.omitStrs) > 0 {
+		for _, field := range d.model.structFields() {
+			if d.columnSelected(field.DbName) {
+				columns = append(columns, d.qualifiedColumn(field.DbName))
+			}
+		}
+	}
+
+	sql := "*"
+	if len(d.joinsClause) > 0 {
+		sql = d.tableName() + ".*"
+	}
+	if len(columns) > 0 {
+		sql = strings.Join(columns, ",")
+	}
+	if d.distinct {
+		sql = "DISTINCT " + sql
+	}
+	return sql
+}
+
+// qualifiedColumn prefixes column with the table name when joins could make
+// it ambiguous. Columns that already name a table are kept.
+func (d *Do) qualifiedColumn(column string) string {
+	if len(d.joinsClause) == 0 || strings.Contains(column, ".") {
+		return column
+	}
+	return d.tableName() + "." + column
+}
+
+func (d *Do) joinsSql() string {
+	var sql string
+	for _, clause := range d.joinsClause {
+		sql += " " + d.bindVars(clause["query"].(string), clause["args"].([]any))
+	}
+	return sql
+}
+
+func (d *Do) limitSql() string {
+	if len(d.limitStr) == 0 {
+		if len(d.offsetStr) > 0 {
+			// MySQL has no OFFSET without LIMIT
+			return " LIMIT 18446744073709551615"
+		}
+		return ""
+	} else {
+		return " LIMIT " + d.limitStr
+	}
+}
+
+func (d *Do) offsetSql() string {
+	if len(d.offsetStr) == 0 {
+		return ""
+	}
+	return " OFFSET " + d.offsetStr
+}
+
+func (d *Do) groupSql() string {
+	if len(d.groupStr) == 0 {
+		return ""
+	}
+	return " GROUP BY " + d.groupStr
+}
+
+func (d *Do) havingSql() string {
+	var conditions []string
+	for _, clause := range d.havingClause {
+		if condition := d.buildWhereCondition(clause); len(condition) > 0 {
+			conditions = append(conditions, condition)
+		}
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " HAVING " + strings.Join(conditions, " AND ")
+}
+
+func (d *Do) orderSql() string {
+	if len(d.orderStrs) == 0 {
+		return ""
+	} else {
+		return " ORDER BY " + strings.Join(d.orderStrs, ",")
+	}
+}
+
+// writeSql is combinedSql for UPDATE and DELETE statements, where MySQL
+// allows no GROUP BY, HAVING or OFFSET.
+func (d *Do) writeSql() string {
+	sql := d.whereSql() + d.orderSql()
+	if len(d.limitStr) > 0 {
+		sql += " LIMIT " + d.limitStr
+	}
+	return sql
+}
+
+func (d *Do) combinedSql() string {
+	return d.whereSql() + d.groupSql() + d.havingSql() + d.orderSql() + d.limitSql() + d.offsetSql()
+}
+
+func (d *Do) createTable() *Do {
+	var sqls, primaryKeys []string
+	for _, field := range d.model.structFields() {
+		if field.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, field.DbName)
+		}
+		sqlType, err := d.model.sqlType(field)
+		if d.err(err) != nil {
+			return d
+		}
+		sql := field.DbName + " " + sqlType
+		if field.NotNull && !field.IsPrimaryKey {
+			sql += " NOT NULL"
+		} else if field.SoftDelete {
+			// not deleted records have no deletion time
+			sql += " NULL"
+		}
+		if len(field.Default) > 0 {
+			sql += " DEFAULT " + field.Default
+		}
+		if field.Unique {
+			sql += " UNIQUE"
+		}
+		sqls = append(sqls, sql)
+	}
+	if len(primaryKeys) > 0 {
+		sqls = append(sqls, "PRIMARY KEY ("+strings.Join(primaryKeys, ",")+")")
+	}
+	d.sql = fmt.Sprintf(
+		"CREATE TABLE %v (%v)",
+		d.tableName(),
+		strings.Join(sqls, ","),
+	)
+	return d
 }
 
 func (d *Do) tableName() string {
+	if len(d.tableStr) > 0 {
+		return d.tableStr
+	}
 	name, err := d.model.tableName()
 	d.err(err)
 	return name
 }
 
+// dbErr records an error returned by the database as a SqlError. When the
+// context has been canceled or has expired the context error is reported as
+// well, since the driver does not always return it as is.
+func (d *Do) dbErr(err error) error {
+	if err != nil {
+		if ctxErr := d.ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+			err = fmt.Errorf("%w: %w", ctxErr, err)
+		}
+		err = &SqlError{Kind: classifyError(err), Err: err, Sql: d.sql, SqlVars: d.sqlVars}
+	}
+	return d.err(err)
+}
+
 func (d *Do) err(err error) error {
 	if err != nil {
 		d.Errors = append(d.Errors, err)
@@ -466,6 +2268,28 @@ func (m *Model) tableName() (str string, err error) {
 		return
 	}
 
+	typ := m.structType()
+	if typ.Kind() != reflect.Struct {
+		err = fmt.Errorf("Model should be a struct, got %v", typ)
+		return
+	}
+	if t, ok := reflect.New(typ).Interface().(tabler); ok {
+		return t.TableName(), err
+	}
+	return m.namingStrategy().TableName(typ.Name()), err
+}
+
+func (m *Model) namingStrategy() NamingStrategy {
+	if m.naming == nil {
+		return DefaultNamingStrategy{}
+	}
+	return m.naming
+}
+
+func (m *Model) structType() reflect.Type {
+	if m.data == nil {
+		return reflect.TypeOf((*any)(nil)).Elem()
+	}
 	t := reflect.TypeOf(m.data)
 	for {
 		c := false
@@ -478,137 +2302,606 @@ func (m *Model) tableName() (str string, err error) {
 			break
 		}
 	}
+	return t
+}
 
-	str = toSnake(t.Name())
-
-	pluralMap := map[string]string{"ch": "ches", "ss": "sses", "sh": "shes", "day": "days", "y": "ies", "x": "xes", "s?": "s"}
-	for key, value := range pluralMap {
-		reg := regexp.MustCompile(key + "$")
-		if reg.MatchString(str) {
-			return reg.ReplaceAllString(str, value), err
+func (m *Model) primaryFields() (fields []Field) {
+	for _, field := range m.structFields() {
+		if field.IsPrimaryKey {
+			fields = append(fields, field)
 		}
 	}
 	return
 }
 
-func (m *Model) primaryKey() string {
-	return "Id"
-}
 func (m *Model) primaryKeyDb() string {
-	return toSnake(m.primaryKey())
+	if fields := m.primaryFields(); len(fields) > 0 {
+		return fields[0].DbName
+	}
+	return ""
+}
+
+// fieldValue returns the value of field when the model is a struct pointer.
+func (m *Model) fieldValue(field *Field) (reflect.Value, bool) {
+	result := reflect.ValueOf(m.data)
+	if result.Kind() != reflect.Ptr || result.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	value, err := result.Elem().FieldByIndexErr(field.index)
+	return value, err == nil
 }
+
+// softDeleteField returns the DeletedAt field of models deleted softly.
+func (m *Model) softDeleteField() *Field {
+	for _, field := range m.structFields() {
+		if field.SoftDelete {
+			return &field
+		}
+	}
+	return nil
+}
+
+func (m *Model) autoIncrementField() *Field {
+	for _, field := range m.primaryFields() {
+		if field.IsAutoIncrement {
+			return &field
+		}
+	}
+	return nil
+}
+
+// primaryKeyZero reports whether the model lacks a complete primary key
+// value. Models that aren't struct pointers never have one.
 func (m *Model) primaryKeyZero() bool {
-	return m.primaryKeyValue() <= 0
+	values := m.primaryKeyValues()
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if reflect.ValueOf(value).IsZero() {
+			return true
+		}
+	}
+	return false
 }
-func (m *Model) primaryKeyValue() int64 {
-	if m.data == nil {
-		return -1
+
+func (m *Model) primaryKeyValues() (values []any) {
+	result := reflect.ValueOf(m.data)
+	if result.Kind() != reflect.Ptr || result.Elem().Kind() != reflect.Struct {
+		return
 	}
-	t := reflect.TypeOf(m.data).Elem()
-	switch t.Kind() {
-	case reflect.Array, reflect.Chan, reflect.Map, reflect.Ptr, reflect.Slice:
-		return 0
-	default:
-		result := reflect.ValueOf(m.data).Elem()
-		value := result.FieldByName(m.primaryKey())
-		if value.IsValid() {
-			return value.Interface().(int64)
-		} else {
-			return 0
+	for _, field := range m.primaryFields() {
+		value, err := result.Elem().FieldByIndexErr(field.index)
+		if err != nil {
+			// a nil embedded pointer holds a zero key
+			values = append(values, reflect.Zero(field.fieldType).Interface())
+			continue
 		}
+		values = append(values, value.Interface())
 	}
+	return
+}
+
+// structFields returns the column definitions of the model's struct type,
+// as described by field names and `gormysql` struct tags. Fields of
+// embedded structs are included as the model's own. Fields tagged with "-",
+// unexported fields and nested structs are not mapped to columns.
+func (m *Model) structFields() (fields []Field) {
+	typ := m.structType()
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+
+	fields = m.parseFields(typ, nil)
+
+	var primaryKeys []int
+	for i, field := range fields {
+		if field.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, i)
+		}
+	}
+	// without tagged keys a field named Id is the primary key
+	if len(primaryKeys) == 0 {
+		for i, field := range fields {
+			if field.Name == "Id" || field.Name == "ID" {
+				fields[i].IsPrimaryKey = true
+				primaryKeys = append(primaryKeys, i)
+			}
+		}
+	}
+	// a single integer key is auto incremented unless told otherwise
+	if len(primaryKeys) == 1 {
+		i := primaryKeys[0]
+		if _, ok := fields[i].settings["AUTO INCREMENT"]; !ok {
+			switch indirectType(fields[i].fieldType).Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				fields[i].IsAutoIncrement = true
+			}
+		}
+	}
+	return
 }
-func (m *Model) fields(operation string) (fields []Field) {
-	typ := reflect.TypeOf(m.data).Elem()
 
+func (m *Model) parseFields(typ reflect.Type, index []int) (fields []Field) {
 	for i := 0; i < typ.NumField(); i++ {
 		p := typ.Field(i)
-		if !p.Anonymous {
-			var field Field
-			field.Name = p.Name
-			field.DbName = toSnake(p.Name)
-			field.IsPrimaryKey = m.primaryKeyDb() == field.DbName
-			field.AutoCreateTime = "created_at" == field.DbName
-			field.AutoUpdateTime = "updated_at" == field.DbName
-			value := reflect.ValueOf(m.data).Elem().FieldByName(p.Name)
-
-			switch operation {
-			case "create":
-				if (field.AutoCreateTime || field.AutoUpdateTime) &&
-					value.Interface().(time.Time).IsZero() {
-					value.Set(reflect.ValueOf(time.Now()))
-				}
-			case "update":
-				if field.AutoUpdateTime {
-					value.Set(reflect.ValueOf(time.Now()))
-				}
+		settings := parseTagSetting(p.Tag.Get("gormysql"))
+		if _, ok := settings["-"]; ok {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if p.Anonymous && isNestedStruct(p.Type) {
+			fields = append(fields, m.parseFields(indirectType(p.Type), fieldIndex)...)
+			continue
+		}
+		if !p.IsExported() || isNestedStruct(p.Type) {
+			continue
+		}
+
+		var field Field
+		field.Name = p.Name
+		field.DbName = m.namingStrategy().ColumnName(p.Name)
+		if column, ok := settings["COLUMN"]; ok {
+			field.DbName = column
+		}
+		if size, err := strconv.Atoi(settings["SIZE"]); err == nil {
+			field.Size = size
+		}
+		if sqlType, ok := settings["TYPE"]; ok {
+			field.SqlType = sqlType
+			if field.Size > 0 && !strings.Contains(sqlType, "(") {
+				field.SqlType = fmt.Sprintf("%v(%d)", sqlType, field.Size)
+			}
+		}
+		_, field.NotNull = settings["NOT NULL"]
+		_, field.Unique = settings["UNIQUE"]
+		field.Default = settings["DEFAULT"]
+		_, field.IsPrimaryKey = settings["PRIMARY KEY"]
+		if autoIncrement, ok := settings["AUTO INCREMENT"]; ok {
+			field.IsAutoIncrement = !strings.EqualFold(autoIncrement, "false")
+		}
+		field.AutoCreateTime = "CreatedAt" == field.Name
+		field.AutoUpdateTime = "UpdatedAt" == field.Name
+		field.SoftDelete = "DeletedAt" == field.Name && isTimeType(p.Type)
+		field.index = fieldIndex
+		field.fieldType = p.Type
+		field.settings = settings
+		fields = append(fields, field)
+	}
+	return
+}
+
+// columnFieldIndexes maps column names to the index paths of the fields
+// that hold them. Columns of nested struct fields are prefixed with the
+// field's column name and "__", like "company__name", so that joined
+// columns can be aliased into them.
+func (m *Model) columnFieldIndexes() map[string][]int {
+	indexes := map[string][]int{}
+	if typ := m.structType(); typ.Kind() == reflect.Struct {
+		m.nestedFieldIndexes(typ, "", nil, indexes, map[reflect.Type]bool{})
+	}
+	return indexes
+}
+
+func (m *Model) nestedFieldIndexes(typ reflect.Type, prefix string, index []int, indexes map[string][]int, visited map[reflect.Type]bool) {
+	visited[typ] = true
+	defer delete(visited, typ)
+
+	var fields []Field
+	if prefix == "" {
+		fields = m.structFields()
+	} else {
+		fields = m.parseFields(typ, nil)
+	}
+	for _, field := range fields {
+		indexes[prefix+field.DbName] = append(append([]int{}, index...), field.index...)
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		p := typ.Field(i)
+		settings := parseTagSetting(p.Tag.Get("gormysql"))
+		if _, ok := settings["-"]; ok || p.Anonymous || !p.IsExported() || !isNestedStruct(p.Type) {
+			continue
+		}
+		nestedType := indirectType(p.Type)
+		if visited[nestedType] {
+			continue
+		}
+		column := m.namingStrategy().ColumnName(p.Name)
+		if name, ok := settings["COLUMN"]; ok {
+			column = name
+		}
+		m.nestedFieldIndexes(nestedType, prefix+column+"__", append(append([]int{}, index...), i), indexes, visited)
+	}
+}
+
+func (m *Model) fields(operation string) (fields []Field) {
+	indirectValue := reflect.ValueOf(m.data).Elem()
+
+	for _, field := range m.structFields() {
+		value, err := indirectValue.FieldByIndexErr(field.index)
+		if err != nil {
+			// a field of a nil embedded pointer
+			continue
+		}
+
+		switch operation {
+		case "create":
+			if (field.AutoCreateTime || field.AutoUpdateTime) && value.IsZero() {
+				setTime(value, time.Now())
+			}
+		case "update":
+			if field.AutoUpdateTime {
+				setTime(value, time.Now())
 			}
+		}
 
-			field.Value = value.Interface()
+		field.Value = value.Interface()
+		if field.SoftDelete && value.IsZero() {
+			// a zero time isn't a valid timestamp
+			field.Value = nil
+		}
+		fields = append(fields, field)
+	}
+	return
+}
 
-			if field.IsPrimaryKey {
-				field.SqlType = getPrimaryKeySqlType(field.Value, 0)
-			} else {
-				field.SqlType = getSqlType(field.Value, 0)
+// assignColumns sets the fields of the model to the values of the matching
+// columns, when the model is a struct pointer and the types fit.
+func (m *Model) assignColumns(values map[string]any) {
+	result := reflect.ValueOf(m.data)
+	if result.Kind() != reflect.Ptr || result.Elem().Kind() != reflect.Struct {
+		return
+	}
+	for _, field := range m.structFields() {
+		value, ok := values[field.DbName]
+		if !ok {
+			continue
+		}
+		fieldValue, err := result.Elem().FieldByIndexErr(field.index)
+		if err != nil {
+			continue
+		}
+		v := reflect.ValueOf(value)
+		switch {
+		case value == nil:
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		case v.Type().AssignableTo(fieldValue.Type()):
+			fieldValue.Set(v)
+		case v.CanInt() || v.CanUint() || v.CanFloat():
+			// numbers of another type, like the int of an untyped constant
+			if v.Type().ConvertibleTo(fieldValue.Type()) && fieldValue.Kind() != reflect.String {
+				fieldValue.Set(v.Convert(fieldValue.Type()))
 			}
-			fields = append(fields, field)
 		}
 	}
+}
+
+// elements returns a model per struct of a slice model, or the model itself
+// for others.
+func (m *Model) elements() (models []*Model) {
+	if !isSliceValue(m.data) {
+		return []*Model{m}
+	}
+	value := reflect.ValueOf(m.data).Elem()
+	for i := 0; i < value.Len(); i++ {
+		elem := value.Index(i)
+		if elem.Kind() != reflect.Ptr {
+			elem = elem.Addr()
+		} else if elem.IsNil() {
+			continue
+		}
+		models = append(models, &Model{data: elem.Interface(), naming: m.naming})
+	}
 	return
 }
 
+// columnFieldNames maps column names to the struct field names that hold
+// them.
+func (m *Model) columnFieldNames() map[string]string {
+	names := map[string]string{}
+	for _, field := range m.structFields() {
+		names[field.DbName] = field.Name
+	}
+	return names
+}
+
+// callMethod calls the model's hook method if it has one. Hooks take no
+// arguments and may return an error.
+func (m *Model) callMethod(method string) error {
+	if m.data == nil {
+		return nil
+	}
+	if isSliceValue(m.data) {
+		for _, model := range m.elements() {
+			if err := model.callMethod(method); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	fm := reflect.ValueOf(m.data).MethodByName(method)
+	if !fm.IsValid() || fm.Type().NumIn() > 0 {
+		return nil
+	}
+	if v := fm.Call(nil); len(v) > 0 {
+		if err, ok := v[0].Interface().(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Model) columnsAndValues(operation string) map[string]any {
 	results := map[string]any{}
 	for _, field := range m.fields(operation) {
-		if !field.IsPrimaryKey {
-			results[field.DbName] = field.Value
+		if field.IsPrimaryKey && (operation != "create" ||
+			(field.IsAutoIncrement && reflect.ValueOf(field.Value).IsZero())) {
+			continue
+		}
+		// leave zero values out of inserts so the column default applies
+		if operation == "create" && len(field.Default) > 0 &&
+			reflect.ValueOf(field.Value).IsZero() {
+			continue
 		}
+		results[field.DbName] = field.Value
 	}
 	return results
 }
 
 //--------- SqlType ---------
 
-func getPrimaryKeySqlType(column interface{}, size int) string {
-	suffix_str := " NOT NULL AUTO_INCREMENT PRIMARY KEY"
-	switch column.(type) {
-	case int, int8, int16, int32, uint, uint8, uint16, uint32:
-		return "int" + suffix_str
-	case int64, uint64:
-		return "bigint" + suffix_str
-	}
-	panic("unsupported sql adaptor, please submit an issue in github")
-}
-
-func getSqlType(column interface{}, size int) string {
-	switch column.(type) {
-	case time.Time:
-		return "timestamp"
-	case bool:
-		return "boolean"
-	case int, int8, int16, int32, uint, uint8, uint16, uint32:
-		return "int"
-	case int64, uint64:
-		return "bigint"
-	case float32, float64:
-		return "double"
-	case []byte:
-		if size > 0 && size < 65532 {
-			return fmt.Sprintf("varbinary(%d)", size)
+// sqlType returns the column type of field, as set by its type tag or
+// mapped from its Go type. Primary keys are NOT NULL either way.
+func (m *Model) sqlType(field Field) (string, error) {
+	var sqlType string
+	var err error
+	if len(field.SqlType) > 0 {
+		sqlType = field.SqlType
+		if field.IsPrimaryKey {
+			sqlType += " NOT NULL"
+			if field.IsAutoIncrement {
+				sqlType += " AUTO_INCREMENT"
+			}
 		}
-		return "longblob"
-	case string:
+	} else if field.IsPrimaryKey {
+		sqlType, err = getPrimaryKeySqlType(field.fieldType, field.Size, field.IsAutoIncrement)
+	} else {
+		sqlType, err = getSqlType(field.fieldType, field.Size)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Field %v.%v: %w", m.structType().Name(), field.Name, err)
+	}
+	return sqlType, nil
+}
+
+func getPrimaryKeySqlType(typ reflect.Type, size int, autoIncrement bool) (string, error) {
+	if !autoIncrement {
+		switch indirectType(typ).Kind() {
+		case reflect.String, reflect.Slice:
+			// text and blob columns can't be keys, so default to a bounded size
+			if size <= 0 || size >= 65532 {
+				size = 255
+			}
+		}
+		sqlType, err := getSqlType(typ, size)
+		return sqlType + " NOT NULL", err
+	}
+
+	suffix_str := " NOT NULL AUTO_INCREMENT"
+	switch indirectType(typ).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "int" + suffix_str, nil
+	case reflect.Int64, reflect.Uint64:
+		return "bigint" + suffix_str, nil
+	}
+	return "", fmt.Errorf("Auto increment key should be an integer, got %v", typ)
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	nullValueTypes = map[reflect.Type]reflect.Type{
+		reflect.TypeOf(sql.NullString{}):  reflect.TypeOf(""),
+		reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+		reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+		reflect.TypeOf(sql.NullInt16{}):   reflect.TypeOf(int16(0)),
+		reflect.TypeOf(sql.NullByte{}):    reflect.TypeOf(byte(0)),
+		reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+		reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+		reflect.TypeOf(sql.NullTime{}):    timeType,
+	}
+)
+
+// getSqlType maps a Go type to a column type. Pointers and sql.Null types
+// map like the type they hold, and named types like their underlying kind.
+func getSqlType(fieldType reflect.Type, size int) (string, error) {
+	typ := indirectType(fieldType)
+	if valueType, ok := nullValueTypes[typ]; ok {
+		typ = valueType
+	} else if typ.PkgPath() == "database/sql" && strings.HasPrefix(typ.Name(), "Null[") {
+		// the generic sql.Null[T]
+		typ = indirectType(typ.Field(0).Type)
+	}
+
+	if typ == timeType {
+		return "timestamp", nil
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "int", nil
+	case reflect.Int64, reflect.Uint64:
+		return "bigint", nil
+	case reflect.Float32, reflect.Float64:
+		return "double", nil
+	case reflect.String:
 		if size > 0 && size < 65532 {
-			return fmt.Sprintf("varchar(%d)", size)
+			return fmt.Sprintf("varchar(%d)", size), nil
 		}
-		return "longtext"
-	default:
-		panic("invalid sql type")
+		return "longtext", nil
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			if size > 0 && size < 65532 {
+				return fmt.Sprintf("varbinary(%d)", size), nil
+			}
+			return "longblob", nil
+		}
+	}
+	return "", fmt.Errorf("Unsupported type %v", fieldType)
+}
+
+// setTime sets a time.Time, *time.Time or sql.NullTime field to t.
+func setTime(value reflect.Value, t time.Time) {
+	switch value.Type() {
+	case timeType:
+		value.Set(reflect.ValueOf(t))
+	case reflect.PointerTo(timeType):
+		value.Set(reflect.ValueOf(&t))
+	case reflect.TypeOf(sql.NullTime{}):
+		value.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
 	}
 }
 
+func isTimeType(t reflect.Type) bool {
+	return t == timeType || t == reflect.PointerTo(timeType) || t == reflect.TypeOf(sql.NullTime{})
+}
+
 //--------- utils ---------
 
+// parseTagSetting parses a struct tag like `column:name;size:255;not null`
+// into a map with upper-cased keys, where underscores count as spaces so
+// that `primary_key` and `primary key` are the same. Flags without a value
+// map to their own key.
+func parseTagSetting(tag string) map[string]string {
+	settings := map[string]string{}
+	for _, str := range strings.Split(tag, ";") {
+		str = strings.TrimSpace(str)
+		if len(str) == 0 {
+			continue
+		}
+		kv := strings.SplitN(str, ":", 2)
+		key := strings.ReplaceAll(kv[0], "_", " ")
+		key = strings.ToUpper(strings.Join(strings.Fields(key), " "))
+		if len(kv) == 2 {
+			settings[key] = strings.TrimSpace(kv[1])
+		} else {
+			settings[key] = key
+		}
+	}
+	return settings
+}
+
+// expandSlice returns the elements of value if it is a slice or an array
+// that should be bound as a list. Byte slices and driver.Valuer
+// implementations are bound as single values.
+func expandSlice(value any) ([]any, bool) {
+	if _, ok := value.(driver.Valuer); ok {
+		return nil, false
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	values := make([]any, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+	return values, true
+}
+
+func isIdentRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
+
+// isColumnName reports whether str is a column name, optionally qualified
+// by its table.
+func isColumnName(str string) bool {
+	for _, part := range strings.Split(str, ".") {
+		if len(part) == 0 {
+			return false
+		}
+		for i, r := range part {
+			if !isIdentRune(r, i == 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// maxBatchBytes bounds the strings and bytes CreateInBatches puts in one
+// statement.
+const maxBatchBytes = 4 << 20
+
+// rowBytes estimates the size row takes in an INSERT statement, from the
+// length of its strings and bytes and a few bytes for any other value.
+func rowBytes(row reflect.Value, fields []Field) (n int) {
+	row = reflect.Indirect(row)
+	for _, field := range fields {
+		value, err := row.FieldByIndexErr(field.index)
+		if err != nil {
+			continue
+		}
+		value = reflect.Indirect(value)
+		switch {
+		case value.Kind() == reflect.String:
+			n += value.Len()
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8:
+			n += value.Len()
+		case value.Type() == reflect.TypeOf(sql.NullString{}):
+			n += len(value.Interface().(sql.NullString).String)
+		}
+		n += 8
+	}
+	return
+}
+
+// isSliceValue reports whether value points to a slice, other than bytes.
+func isSliceValue(value any) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice && v.Elem().Type().Elem().Kind() != reflect.Uint8
+}
+
+// isNestedStruct reports whether t is a struct, or a pointer to one, that
+// holds columns of its own rather than being stored in a single column.
+func isNestedStruct(t reflect.Type) bool {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+	valuer := reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scanner := reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	return !t.Implements(valuer) && !reflect.PointerTo(t).Implements(valuer) &&
+		!reflect.PointerTo(t).Implements(scanner)
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// fieldByIndexAlloc is like FieldByIndex but allocates nil pointers to
+// structs on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
 func toSnake(s string) string {
 	buf := bytes.NewBufferString("")
 	for i, v := range s {
@@ -619,14 +2912,3 @@ func toSnake(s string) string {
 	}
 	return strings.ToLower(buf.String())
 }
-
-func snakeToUpperCamel(s string) string {
-	buf := bytes.NewBufferString("")
-	for _, v := range strings.Split(s, "_") {
-		if len(v) > 0 {
-			buf.WriteString(strings.ToUpper(v[:1]))
-			buf.WriteString(v[1:])
-		}
-	}
-	return buf.String()
-}
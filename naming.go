@@ -0,0 +1,101 @@
+package gormysql
+
+import (
+	"regexp"
+	"strings"
+)
+
+// NamingStrategy converts Go struct and field names into table and column
+// names. Set DB.NamingStrategy to map models onto an existing schema.
+type NamingStrategy interface {
+	TableName(structName string) string
+	ColumnName(fieldName string) string
+}
+
+// DefaultNamingStrategy snake cases names and pluralizes table names.
+type DefaultNamingStrategy struct {
+	// TablePrefix is prepended to every generated table name.
+	TablePrefix string
+	// SingularTable keeps table names singular, e.g. "user" for User.
+	SingularTable bool
+}
+
+func (ns DefaultNamingStrategy) TableName(structName string) string {
+	name := toSnake(structName)
+	if !ns.SingularTable {
+		name = pluralize(name)
+	}
+	return ns.TablePrefix + name
+}
+
+func (ns DefaultNamingStrategy) ColumnName(fieldName string) string {
+	return toSnake(fieldName)
+}
+
+// tabler is implemented by models that name their own table. Its result is
+// used as is, without the naming strategy being applied.
+type tabler interface {
+	TableName() string
+}
+
+var (
+	pluralIrregulars = map[string]string{
+		"person": "people",
+		"man":    "men",
+		"woman":  "women",
+		"child":  "children",
+		"mouse":  "mice",
+		"goose":  "geese",
+		"foot":   "feet",
+		"tooth":  "teeth",
+		"ox":     "oxen",
+	}
+	pluralUncountables = map[string]bool{
+		"equipment":   true,
+		"information": true,
+		"money":       true,
+		"news":        true,
+		"series":      true,
+		"species":     true,
+		"sheep":       true,
+		"fish":        true,
+		"rice":        true,
+	}
+	// checked in order, the first matching rule wins
+	pluralRules = []struct {
+		reg         *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile(`(quiz)$`), "${1}zes"},
+		{regexp.MustCompile(`(matr|vert|ind)(?:ix|ex)$`), "${1}ices"},
+		{regexp.MustCompile(`(x|ch|ss|sh|zz)$`), "${1}es"},
+		{regexp.MustCompile(`([^aeiouy]|qu)y$`), "${1}ies"},
+		{regexp.MustCompile(`([^f])fe$`), "${1}ves"},
+		{regexp.MustCompile(`([lr])f$`), "${1}ves"},
+		{regexp.MustCompile(`sis$`), "ses"},
+		{regexp.MustCompile(`(us)$`), "${1}es"},
+		{regexp.MustCompile(`s$`), "s"},
+		{regexp.MustCompile(`$`), "s"},
+	}
+)
+
+// pluralize returns the plural of the last word of a snake cased name.
+func pluralize(name string) string {
+	prefix, word := "", name
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		prefix, word = name[:i+1], name[i+1:]
+	}
+
+	if pluralUncountables[word] {
+		return name
+	}
+	if plural, ok := pluralIrregulars[word]; ok {
+		return prefix + plural
+	}
+	for _, rule := range pluralRules {
+		if rule.reg.MatchString(word) {
+			return prefix + rule.reg.ReplaceAllString(word, rule.replacement)
+		}
+	}
+	return name
+}
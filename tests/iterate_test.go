@@ -0,0 +1,46 @@
+//go:build go1.23
+
+package gormysql_test
+
+import (
+	"testing"
+
+	"github.com/demouth/gormysql"
+)
+
+func TestIterate(t *testing.T) {
+	db.Save(&User{Name: "iterate", Age: 1})
+	db.Save(&User{Name: "iterate", Age: 2})
+	db.Save(&User{Name: "iterate", Age: 3})
+
+	var ages []int64
+	for user, err := range gormysql.Iterate[User](db.Where("name = ?", "iterate").Order("age")) {
+		if err != nil {
+			t.Fatalf("Should iterate users, but got %v", err)
+		}
+		ages = append(ages, user.Age)
+		if user.Age == 2 {
+			break
+		}
+	}
+	if len(ages) != 2 || ages[1] != 2 {
+		t.Errorf("Should stop iterating on break, but got %v", ages)
+	}
+
+	var names []string
+	for name, err := range gormysql.Iterate[string](db.Table("users").Select("name").Where("name = ?", "iterate")) {
+		if err != nil {
+			t.Fatalf("Should iterate names, but got %v", err)
+		}
+		names = append(names, name)
+	}
+	if len(names) != 3 {
+		t.Errorf("Should iterate every name, but got %v", names)
+	}
+
+	for _, err := range gormysql.Iterate[map[string]any](db.Where("name = ?", "iterate")) {
+		if err == nil {
+			t.Errorf("Should get an error when iterating without model or table")
+		}
+	}
+}
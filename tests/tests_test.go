@@ -1,8 +1,13 @@
 package gormysql_test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,7 +36,92 @@ type Product struct {
 	AfterSaveCallTimes    int64
 	BeforeDeleteCallTimes int64
 	AfterDeleteCallTimes  int64
+	AfterFindCallTimes    int64
 }
+type Tag struct {
+	Id    int64
+	Name  string `gormysql:"column:tag_name;size:64;not null;unique"`
+	Color string `gormysql:"size:16;default:'white'"`
+	Note  string `gormysql:"-"`
+}
+type Book struct {
+	BookId uint32 `gormysql:"primary_key"`
+	Title  string
+}
+type Invoice struct {
+	Number string `gormysql:"primary_key;size:36"`
+	Amount int64
+}
+type Voucher struct {
+	Code  string `gormysql:"primary_key;type:char(36)"`
+	Value int64
+}
+type Membership struct {
+	UserId  int64 `gormysql:"primary_key"`
+	GroupId int64 `gormysql:"primary_key"`
+	Role    string
+}
+type Person struct {
+	Id   int64
+	Name string
+}
+type LegacyAccount struct {
+	Id    int64
+	Login string
+}
+
+func (LegacyAccount) TableName() string {
+	return "tbl_account"
+}
+
+type BaseModel struct {
+	Id        int64
+	CreatedAt time.Time
+}
+type Company struct {
+	BaseModel
+	Name string
+}
+type Level int
+type Profile struct {
+	Id        *int64
+	Nickname  *string `gormysql:"size:32"`
+	Level     Level
+	Bio       sql.NullString
+	Score     sql.NullFloat64
+	CreatedAt *time.Time
+}
+type Comment struct {
+	Id        int64
+	Body      string
+	DeletedAt *time.Time
+}
+type Coupon struct {
+	Code      string `gormysql:"primary_key;size:32"`
+	Discount  int64
+	DeletedAt *time.Time
+}
+type Draft struct {
+	Id        int64
+	Title     string
+	DeletedAt time.Time
+}
+type Preference struct {
+	UserId int64
+	Theme  string
+}
+type Branch struct {
+	*BaseModel
+	Name string
+}
+type Employee struct {
+	Id        int64
+	Name      string
+	CompanyId int64
+	Company   *Company
+}
+
+const dsn = "gorm:gorm@tcp(localhost:9910)/gorm?charset=utf8&parseTime=True&loc=Local"
 
 var (
 	db                 gormysql.DB
@@ -40,7 +130,7 @@ var (
 
 func init() {
 	var err error
-	db, err = gormysql.Open("gorm:gorm@tcp(localhost:9910)/gorm?charset=utf8&parseTime=True&loc=Local")
+	db, err = gormysql.Open(dsn)
 	if err != nil {
 		panic(fmt.Sprintf("No error should happen when connect database, but got %+v", err))
 	}
@@ -51,6 +141,22 @@ func init() {
 	}
 
 	db.Exec("drop table IF EXISTS products;")
+	db.Exec("drop table IF EXISTS tags;")
+	db.Exec("drop table IF EXISTS books;")
+	db.Exec("drop table IF EXISTS invoices;")
+	db.Exec("drop table IF EXISTS memberships;")
+	db.Exec("drop table IF EXISTS people;")
+	db.Exec("drop table IF EXISTS tbl_account;")
+	db.Exec("drop table IF EXISTS t_person;")
+	db.Exec("drop table IF EXISTS companies;")
+	db.Exec("drop table IF EXISTS employees;")
+	db.Exec("drop table IF EXISTS profiles;")
+	db.Exec("drop table IF EXISTS comments;")
+	db.Exec("drop table IF EXISTS drafts;")
+	db.Exec("drop table IF EXISTS vouchers;")
+	db.Exec("drop table IF EXISTS branches;")
+	db.Exec("drop table IF EXISTS preferences;")
+	db.Exec("drop table IF EXISTS coupons;")
 
 	orm := db.CreateTable(&User{})
 	if orm.Error != nil {
@@ -59,6 +165,15 @@ func init() {
 
 	db.CreateTable(&Product{})
 
+	if err = db.CreateTable(&Tag{}).Error; err != nil {
+		panic(fmt.Sprintf("No error should happen when create table with tags, but got %+v", err))
+	}
+	for _, value := range []any{&Book{}, &Invoice{}, &Membership{}, &Person{}, &LegacyAccount{}, &Company{}, &Employee{}, &Profile{}, &Comment{}, &Draft{}, &Voucher{}, &Branch{}, &Preference{}, &Coupon{}} {
+		if err = db.CreateTable(value).Error; err != nil {
+			panic(fmt.Sprintf("No error should happen when create table with primary keys, but got %+v", err))
+		}
+	}
+
 	var shortForm = "2006-01-02 15:04:05"
 	t1, _ = time.Parse(shortForm, "2000-10-27 12:02:40")
 	t2, _ = time.Parse(shortForm, "2002-01-01 00:00:00")
@@ -290,8 +405,7 @@ func TestComplexWhere(t *testing.T) {
 	db.Where("name in (?)", []string{"1", "3"}).Find(&users)
 
 	if len(users) != 3 {
-		// FIXME
-		// t.Errorf("Should only found 3 users's name in (1, 3), but have %v", len(users))
+		t.Errorf("Should only found 3 users's name in (1, 3), but have %v", len(users))
 	}
 
 	var user_ids []int64
@@ -301,16 +415,14 @@ func TestComplexWhere(t *testing.T) {
 	users = []User{}
 	db.Where("id in (?)", user_ids).Find(&users)
 	if len(users) != 3 {
-		// FIXME
-		// t.Errorf("Should only found 3 users's name in (1, 3) - search by id, but have %v", len(users))
+		t.Errorf("Should only found 3 users's name in (1, 3) - search by id, but have %v", len(users))
 	}
 
 	users = []User{}
 	db.Where("name in (?)", []string{"1", "2"}).Find(&users)
 
 	if len(users) != 2 {
-		// FIXME
-		// t.Errorf("Should only found 2 users's name in (1, 2), but have %v", len(users))
+		t.Errorf("Should only found 2 users's name in (1, 2), but have %v", len(users))
 	}
 
 	user_ids = []int64{}
@@ -320,14 +432,1378 @@ func TestComplexWhere(t *testing.T) {
 	users = []User{}
 	db.Where("id in (?)", user_ids).Find(&users)
 	if len(users) != 2 {
-		// FIXME
-		// t.Errorf("Should only found 2 users's name in (1, 2) - search by id, but have %v", len(users))
+		t.Errorf("Should only found 2 users's name in (1, 2) - search by id, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where("id in (?)", user_ids[0]).Find(&users)
+	if len(users) != 1 {
+		t.Errorf("Should only found 1 users's name in (1, 2) - search by the first id, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where("id in (?)", []int64{}).Find(&users)
+	if len(users) != 0 {
+		t.Errorf("Should find nothing with an empty list, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Find(&users, user_ids)
+	if len(users) != 2 {
+		t.Errorf("Should find users by a list of primary keys, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where(map[string]any{"name": []string{"1", "2"}}).Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should find users with a list in map condition, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where("name = ?", "3").Not("age in (?)", []int64{22}).Or("name in (?)", []string{"1"}).Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should expand lists in not and or conditions, but have %v", len(users))
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("No error should happen when begin transaction, but got %+v", err)
+	}
+	u := User{Name: "transaction", Age: 1, Birthday: t1}
+	if err := tx.Save(&u).Error; err != nil {
+		t.Errorf("No error should happen when save in transaction, but got %+v", err)
+	}
+	if tx.First(&User{}, "name = ?", "transaction").Error != nil {
+		t.Errorf("Should find saved record inside transaction")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("No error should happen when rollback, but got %+v", err)
+	}
+	if db.First(&User{}, "name = ?", "transaction").Error == nil {
+		t.Errorf("Should not find record after rollback")
+	}
+
+	tx, _ = db.Begin()
+	tx.Save(&User{Name: "transaction-commit", Age: 1, Birthday: t1})
+	if err := tx.Commit(); err != nil {
+		t.Errorf("No error should happen when commit, but got %+v", err)
+	}
+	if db.First(&User{}, "name = ?", "transaction-commit").Error != nil {
+		t.Errorf("Should find record after commit")
+	}
+}
+
+func TestTransactionFunc(t *testing.T) {
+	err := db.Transaction(func(tx *gormysql.DB) error {
+		tx.Save(&User{Name: "transaction-func", Age: 1, Birthday: t1})
+		return fmt.Errorf("rollback")
+	})
+	if err == nil || err.Error() != "rollback" {
+		t.Errorf("Should return the error from the transaction func, but got %+v", err)
+	}
+	if db.First(&User{}, "name = ?", "transaction-func").Error == nil {
+		t.Errorf("Should not find record when the transaction func returned error")
+	}
+
+	func() {
+		defer func() { recover() }()
+		db.Transaction(func(tx *gormysql.DB) error {
+			tx.Save(&User{Name: "transaction-panic", Age: 1, Birthday: t1})
+			panic("rollback")
+		})
+	}()
+	if db.First(&User{}, "name = ?", "transaction-panic").Error == nil {
+		t.Errorf("Should not find record when the transaction func panicked")
+	}
+
+	err = db.Transaction(func(tx *gormysql.DB) error {
+		return tx.Save(&User{Name: "transaction-func-commit", Age: 1, Birthday: t1}).Error
+	})
+	if err != nil {
+		t.Errorf("No error should happen when the transaction func succeeded, but got %+v", err)
+	}
+	if db.First(&User{}, "name = ?", "transaction-func-commit").Error != nil {
+		t.Errorf("Should find record committed by the transaction func")
+	}
+}
+
+func TestNestedTransaction(t *testing.T) {
+	err := db.Transaction(func(tx *gormysql.DB) error {
+		tx.Save(&User{Name: "nested-outer", Age: 1, Birthday: t1})
+
+		tx.Transaction(func(tx2 *gormysql.DB) error {
+			tx2.Save(&User{Name: "nested-rollback", Age: 1, Birthday: t1})
+			return fmt.Errorf("rollback nested")
+		})
+
+		return tx.Transaction(func(tx2 *gormysql.DB) error {
+			tx2.Save(&User{Name: "nested-commit", Age: 1, Birthday: t1})
+			return tx2.Transaction(func(tx3 *gormysql.DB) error {
+				return tx3.Save(&User{Name: "nested-deep", Age: 1, Birthday: t1}).Error
+			})
+		})
+	})
+	if err != nil {
+		t.Errorf("No error should happen in nested transaction, but got %+v", err)
+	}
+
+	for _, name := range []string{"nested-outer", "nested-commit", "nested-deep"} {
+		if db.First(&User{}, "name = ?", name).Error != nil {
+			t.Errorf("Should find record %v saved in nested transaction", name)
+		}
+	}
+	if db.First(&User{}, "name = ?", "nested-rollback").Error == nil {
+		t.Errorf("Should not find record rolled back to savepoint")
+	}
+
+	tx, _ := db.Begin()
+	if _, err := tx.Begin(); err == nil {
+		t.Errorf("Should not begin a transaction inside a transaction")
+	}
+	tx.Rollback()
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var users []User
+	orm := db.WithContext(ctx).Where("name = ?", "3").Find(&users)
+	if !errors.Is(orm.Error, context.Canceled) {
+		t.Errorf("Should return context canceled error, but got %+v", orm.Error)
+	}
+
+	if err := db.WithContext(ctx).Save(&User{Name: "canceled", Age: 1, Birthday: t1}).Error; !errors.Is(err, context.Canceled) {
+		t.Errorf("Should return context canceled error when save, but got %+v", err)
+	}
+
+	if err := db.Where("name = ?", "3").WithContext(ctx).Find(&users).Error; !errors.Is(err, context.Canceled) {
+		t.Errorf("Should return context canceled error from chain context, but got %+v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := db.WithContext(ctx).Exec("SELECT SLEEP(1)").Error; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Should return deadline exceeded error, but got %+v", err)
+	}
+
+	if err := db.WithContext(context.Background()).First(&User{}, "name = ?", "3").Error; err != nil {
+		t.Errorf("No error should happen with a live context, but got %+v", err)
+	}
+}
+
+func TestStructTag(t *testing.T) {
+	tag := Tag{Name: "golang", Note: "not saved"}
+	if err := db.Save(&tag).Error; err != nil {
+		t.Errorf("No error should happen when save model with tags, but got %+v", err)
+	}
+
+	var found Tag
+	db.First(&found, "tag_name = ?", "golang")
+	if found.Id != tag.Id || found.Name != "golang" {
+		t.Errorf("Should find record by tagged column name, but got %+v", found)
+	}
+	if found.Color != "white" {
+		t.Errorf("Should apply column default, but got %v", found.Color)
+	}
+	if found.Note != "" {
+		t.Errorf("Should not save ignored field, but got %v", found.Note)
+	}
+
+	if db.Save(&Tag{Name: "golang"}).Error == nil {
+		t.Errorf("Should raise error when saving duplicate unique column")
+	}
+}
+
+func TestPrimaryKey(t *testing.T) {
+	book := Book{Title: "primary key"}
+	db.Save(&book)
+	if book.BookId == 0 {
+		t.Errorf("Should set auto increment primary key with custom name after create")
+	}
+	book.Title = "primary key updated"
+	db.Save(&book)
+	var found Book
+	if db.First(&found, book.BookId).Error != nil || found.Title != "primary key updated" {
+		t.Errorf("Should find updated record by custom primary key, but got %+v", found)
+	}
+
+	invoice := Invoice{Number: "c4d0a8a8-0d4b-4a47-9d0a-6f6e0a1e4a01", Amount: 100}
+	if err := db.Save(&invoice).Error; err != nil {
+		t.Errorf("No error should happen when create record with string primary key, but got %+v", err)
+	}
+	invoice.Amount = 200
+	if err := db.Save(&invoice).Error; err != nil {
+		t.Errorf("No error should happen when update record with string primary key, but got %+v", err)
+	}
+	found2 := Invoice{Number: invoice.Number}
+	if db.First(&found2).Error != nil || found2.Amount != 200 {
+		t.Errorf("Should find record by string primary key, but got %+v", found2)
+	}
+
+	var columnType string
+	db.Raw("SELECT COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?", "vouchers", "code").Scan(&columnType)
+	if columnType != "char(36)" {
+		t.Errorf("Should use the type tag of primary keys, but got %v", columnType)
+	}
+	if err := db.Save(&Voucher{Code: "c4d0a8a8-0d4b-4a47-9d0a-6f6e0a1e4a01", Value: 1}).Error; err != nil {
+		t.Errorf("No error should happen when create record with typed primary key, but got %+v", err)
+	}
+
+	db.Save(&Membership{UserId: 1, GroupId: 1, Role: "owner"})
+	db.Save(&Membership{UserId: 1, GroupId: 2, Role: "member"})
+	db.Save(&Membership{UserId: 1, GroupId: 2, Role: "admin"})
+	var memberships []Membership
+	db.Find(&memberships, "user_id = ?", 1)
+	if len(memberships) != 2 {
+		t.Errorf("Should update record with composite primary key instead of creating, but got %+v", memberships)
+	}
+	db.Delete(&Membership{UserId: 1, GroupId: 1})
+	if db.First(&Membership{UserId: 1, GroupId: 1}).Error == nil {
+		t.Errorf("Should delete record by composite primary key")
+	}
+	member := Membership{UserId: 1, GroupId: 2}
+	if db.First(&member).Error != nil || member.Role != "admin" {
+		t.Errorf("Should not delete other records with partially matching key, but got %+v", member)
+	}
+}
+
+func TestTableName(t *testing.T) {
+	if err := db.Save(&Person{Name: "person"}).Error; err != nil {
+		t.Errorf("Should save person into table people, but got %+v", err)
+	}
+	if err := db.Exec("SELECT * FROM people").Error; err != nil {
+		t.Errorf("Table people should exist, but got %+v", err)
+	}
+
+	if err := db.Save(&LegacyAccount{Login: "legacy"}).Error; err != nil {
+		t.Errorf("Should save into table named by TableName, but got %+v", err)
+	}
+	var account LegacyAccount
+	if db.First(&account, "login = ?", "legacy").Error != nil {
+		t.Errorf("Should find record from table named by TableName")
+	}
+
+	prefixed := db
+	prefixed.NamingStrategy = gormysql.DefaultNamingStrategy{TablePrefix: "t_", SingularTable: true}
+	if err := prefixed.CreateTable(&Person{}).Error; err != nil {
+		t.Errorf("Should create table with naming strategy, but got %+v", err)
+	}
+	prefixed.Save(&Person{Name: "prefixed"})
+	var people []Person
+	prefixed.Find(&people)
+	if len(people) != 1 || people[0].Name != "prefixed" {
+		t.Errorf("Should find records from table t_person, but got %+v", people)
+	}
+}
+
+func (s *Product) BeforeCreate() (err error) {
+	if s.Code == "Invalid" {
+		err = errors.New("invalid product")
+	}
+	s.BeforeCreateCallTimes = s.BeforeCreateCallTimes + 1
+	return
+}
+
+func (s *Product) BeforeUpdate() (err error) {
+	if s.Code == "dont_update" {
+		err = errors.New("Can't update")
+	}
+	s.BeforeUpdateCallTimes = s.BeforeUpdateCallTimes + 1
+	return
+}
+
+func (s *Product) BeforeSave() (err error) {
+	if s.Code == "dont_save" {
+		err = errors.New("Can't save")
+	}
+	s.BeforeSaveCallTimes = s.BeforeSaveCallTimes + 1
+	return
+}
+
+func (s *Product) AfterCreate() {
+	s.AfterCreateCallTimes = s.AfterCreateCallTimes + 1
+}
+
+func (s *Product) AfterUpdate() {
+	s.AfterUpdateCallTimes = s.AfterUpdateCallTimes + 1
+}
+
+func (s *Product) AfterSave() (err error) {
+	if s.Code == "after_save_error" {
+		err = errors.New("Can't save")
+	}
+	s.AfterSaveCallTimes = s.AfterSaveCallTimes + 1
+	return
+}
+
+func (s *Product) BeforeDelete() (err error) {
+	if s.Code == "dont_delete" {
+		err = errors.New("Can't delete")
+	}
+	s.BeforeDeleteCallTimes = s.BeforeDeleteCallTimes + 1
+	return
+}
+
+func (s *Product) AfterDelete() {
+	s.AfterDeleteCallTimes = s.AfterDeleteCallTimes + 1
+}
+
+func (s *Product) AfterFind() {
+	s.AfterFindCallTimes = s.AfterFindCallTimes + 1
+}
+
+func (s *Product) GetCallTimes() []int64 {
+	return []int64{s.BeforeCreateCallTimes, s.BeforeSaveCallTimes, s.BeforeUpdateCallTimes, s.AfterCreateCallTimes, s.AfterSaveCallTimes, s.AfterUpdateCallTimes, s.BeforeDeleteCallTimes, s.AfterDeleteCallTimes}
+}
+
+func TestRunCallbacks(t *testing.T) {
+	p := Product{Code: "unique_code", Price: 100}
+	db.Save(&p)
+	if !reflect.DeepEqual(p.GetCallTimes(), []int64{1, 1, 0, 1, 1, 0, 0, 0}) {
+		t.Errorf("Callbacks should be invoked successfully, %v", p.GetCallTimes())
+	}
+
+	db.Where("code = ?", "unique_code").First(&p)
+	if !reflect.DeepEqual(p.GetCallTimes(), []int64{1, 1, 0, 0, 0, 0, 0, 0}) {
+		t.Errorf("After callbacks values are not saved, %v", p.GetCallTimes())
+	}
+	if p.AfterFindCallTimes != 1 {
+		t.Errorf("AfterFind should be invoked when query, %v", p.AfterFindCallTimes)
+	}
+
+	p.Price = 200
+	db.Save(&p)
+	if !reflect.DeepEqual(p.GetCallTimes(), []int64{1, 2, 1, 0, 1, 1, 0, 0}) {
+		t.Errorf("After update callbacks should be invoked successfully, %v", p.GetCallTimes())
+	}
+
+	db.Delete(&p)
+	if !reflect.DeepEqual(p.GetCallTimes(), []int64{1, 2, 1, 0, 1, 1, 1, 1}) {
+		t.Errorf("After delete callbacks should be invoked successfully, %v", p.GetCallTimes())
+	}
+
+	if db.Where("code = ?", "unique_code").First(&p).Error == nil {
+		t.Errorf("Can't find a deleted record")
+	}
+
+	var products []Product
+	db.Save(&Product{Code: "find_callback"})
+	db.Find(&products, "code = ?", "find_callback")
+	if len(products) != 1 || products[0].AfterFindCallTimes != 1 {
+		t.Errorf("AfterFind should be invoked for each found record, %+v", products)
+	}
+}
+
+func TestCallbacksWithErrors(t *testing.T) {
+	p := Product{Code: "Invalid", Price: 100}
+	if db.Save(&p).Error == nil {
+		t.Errorf("An error from before create callbacks happened when create with invalid value")
+	}
+	if db.Where("code = ?", "Invalid").First(&Product{}).Error == nil {
+		t.Errorf("Should not save record that have errors")
+	}
+
+	if db.Save(&Product{Code: "dont_save", Price: 100}).Error == nil {
+		t.Errorf("An error from before save callbacks happened when create with invalid value")
+	}
+
+	p2 := Product{Code: "update_callback", Price: 100}
+	db.Save(&p2)
+	p2.Code = "dont_update"
+	if db.Save(&p2).Error == nil {
+		t.Errorf("An error from before update callbacks happened when update with invalid value")
+	}
+	if db.Where("code = ?", "update_callback").First(&Product{}).Error != nil {
+		t.Errorf("Record Should not be updated due to errors happened in before update callback")
+	}
+
+	p3 := Product{Code: "dont_delete", Price: 100}
+	db.Save(&p3)
+	if db.Delete(&p3).Error == nil {
+		t.Errorf("An error from before delete callbacks happened when delete")
+	}
+	if db.Where("code = ?", "dont_delete").First(&Product{}).Error != nil {
+		t.Errorf("Record Should not be deleted due to errors happened in before delete callback")
+	}
+
+	p4 := Product{Code: "after_save_error", Price: 100}
+	if db.Save(&p4).Error == nil {
+		t.Errorf("An error from after save callbacks should be returned")
+	}
+}
+
+func TestCallbackRegistry(t *testing.T) {
+	cdb, err := gormysql.Open(dsn)
+	if err != nil {
+		t.Fatalf("No error should happen when connect database, but got %+v", err)
+	}
+
+	var called []string
+	cdb.Callback().Create().Before("gormysql:create").Register("test:before_create", func(d *gormysql.Do) {
+		called = append(called, "before_create:"+d.TableName())
+	})
+	cdb.Callback().Create().After("gormysql:create").Register("test:after_create", func(d *gormysql.Do) {
+		called = append(called, "after_create:"+d.Sql())
+	})
+	cdb.Callback().Query().Before("gormysql:query").Register("test:scope", func(d *gormysql.Do) {
+		d.Scope("age = ?", 22)
+	})
+
+	cdb.Save(&User{Name: "callback", Age: 22, Birthday: t1})
+	if len(called) != 2 || called[0] != "before_create:users" || !strings.HasPrefix(called[1], "after_create:INSERT INTO users") {
+		t.Errorf("Registered callbacks should be called in order, but got %v", called)
+	}
+
+	var users []User
+	cdb.Find(&users)
+	for _, user := range users {
+		if user.Age != 22 {
+			t.Errorf("Query callback should scope all queries, but got %+v", user)
+		}
+	}
+	if len(users) == 0 {
+		t.Errorf("Should find users with the scoped query")
+	}
+
+	users = nil
+	cdb.Where("name = ?", "1").Or("name = ?", "2").Find(&users)
+	for _, user := range users {
+		if user.Age != 22 {
+			t.Errorf("Query callback should scope Or conditions too, but got %+v", user)
+		}
+	}
+
+	var count, scoped int64
+	cdb.Model(&User{}).Count(&count)
+	db.Model(&User{}).Where("age = ?", 22).Count(&scoped)
+	if count != scoped {
+		t.Errorf("Query callback should scope Count, but got %v, expected %v", count, scoped)
+	}
+	var plucked, scanned []int64
+	cdb.Model(&User{}).Pluck("age", &plucked)
+	cdb.Model(&User{}).Select("age").Scan(&scanned)
+	for _, age := range append(plucked, scanned...) {
+		if age != 22 {
+			t.Errorf("Query callback should scope Pluck and Scan, but got %v and %v", plucked, scanned)
+		}
+	}
+	rows, err := cdb.Model(&User{}).Rows()
+	if err != nil {
+		t.Fatalf("No error should happen when query rows, but got %+v", err)
+	}
+	for rows.Next() {
+		var user User
+		cdb.ScanRows(rows, &user)
+		if user.Age != 22 {
+			t.Errorf("Query callback should scope Rows, but got %+v", user)
+		}
+	}
+	rows.Close()
+
+	cdb.Callback().Create().Remove("test:before_create")
+	cdb.Callback().Create().Replace("test:after_create", func(d *gormysql.Do) {
+		called = append(called, "replaced")
+	})
+	called = nil
+	cdb.Save(&User{Name: "callback2", Age: 22, Birthday: t1})
+	if len(called) != 1 || called[0] != "replaced" {
+		t.Errorf("Callbacks should be removed and replaced, but got %v", called)
+	}
+
+	cdb.Callback().Create().Before("gormysql:create").Register("test:abort", func(d *gormysql.Do) {
+		d.AddError(errors.New("aborted"))
+	})
+	if cdb.Save(&User{Name: "callback3", Age: 22, Birthday: t1}).Error == nil {
+		t.Errorf("Errors added by callbacks should abort the operation")
+	}
+	if db.First(&User{}, "name = ?", "callback3").Error == nil {
+		t.Errorf("Should not create record when a callback added an error")
+	}
+
+	users = nil
+	db.Find(&users)
+	if len(users) < 2 {
+		t.Errorf("Callbacks registered on another DB shouldn't affect this one")
+	}
+}
+
+func TestOrAndNot(t *testing.T) {
+	var users []User
+	db.Where("name = ?", "1").Or("name = ?", "2").Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should find 2 users with name 1 or 2, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Or("name = ?", "1").Or("name = ?", "2").Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should find 2 users with only or conditions, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where("name = ?", "3").Where("age = ?", 22).Or("name = ?", "2").Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should find 2 users with (name 3 and age 22) or name 2, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where("age >= ?", 20).Not("name = ?", "3").Find(&users)
+	for _, user := range users {
+		if user.Name == "3" || user.Age < 20 {
+			t.Errorf("Should not find user with name 3 or younger than 20, but got %+v", user)
+		}
+	}
+	if len(users) != 2 {
+		t.Errorf("Should find 2 users older than 20 with name not 3, but have %v", len(users))
+	}
+
+	var user User
+	db.First(&user, "name = ?", "1")
+	users = []User{}
+	db.Where("name in (?, ?)", "1", "2").Not(user.Id).Find(&users)
+	if len(users) != 1 || users[0].Name != "2" {
+		t.Errorf("Should exclude user by primary key, but got %+v", users)
+	}
+
+	var u User
+	db.Not("name = ?", "1").Or("name = ?", "1").First(&u, "age = ?", 18)
+	if u.Name != "1" {
+		t.Errorf("Should match or condition with inline condition, but got %+v", u)
+	}
+}
+
+func TestStructAndMapCondition(t *testing.T) {
+	var users []User
+	db.Where(map[string]any{"name": "3", "age": 22}).Find(&users)
+	if len(users) != 1 || users[0].Age != 22 {
+		t.Errorf("Should find user with map condition, but got %+v", users)
+	}
+
+	users = []User{}
+	db.Where(&User{Name: "3"}).Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should find users with struct condition using non-zero fields only, but have %v", len(users))
+	}
+
+	var user User
+	db.First(&user, User{Name: "3", Age: 24})
+	if user.Age != 24 {
+		t.Errorf("Should find user with inline struct condition, but got %+v", user)
+	}
+
+	users = []User{}
+	db.Find(&users, map[string]any{"name": "3"})
+	if len(users) != 2 {
+		t.Errorf("Should find users with inline map condition, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where(map[string]any{"name": nil}).Find(&users)
+	if len(users) != 0 {
+		t.Errorf("Should match nil map value with IS NULL, but got %+v", users)
 	}
 
-	// FIXME
-	// users = []User{}
-	// db.Where("id in (?)", user_ids[0]).Find(&users)
-	// if len(users) != 1 {
-	// 	t.Errorf("Should only found 1 users's name in (1, 2) - search by the first id, but have %v", len(users))
-	// }
+	users = []User{}
+	db.Where(User{Name: "3"}).Or(map[string]any{"name": "1"}).Not(&User{Age: 24}).Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should combine struct and map conditions with or and not, but got %+v", users)
+	}
+
+	tag := Tag{Name: "condition"}
+	db.Save(&tag)
+	var found Tag
+	db.First(&found, Tag{Name: "condition"})
+	if found.Id != tag.Id {
+		t.Errorf("Should use tagged column names in struct condition, but got %+v", found)
+	}
+}
+
+func TestExecWithArgs(t *testing.T) {
+	db.Save(&User{Name: "exec1", Age: 1, Birthday: t1})
+	db.Save(&User{Name: "exec2", Age: 1, Birthday: t1})
+	if err := db.Exec("UPDATE users SET age = ? WHERE name IN (?)", 99, []string{"exec1", "exec2"}).Error; err != nil {
+		t.Errorf("No error should happen when exec with args, but got %+v", err)
+	}
+	var users []User
+	db.Where("age = ?", 99).Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should update users with raw exec, but have %v", len(users))
+	}
+}
+
+func TestNamedParameters(t *testing.T) {
+	var users []User
+	db.Where("name = @name AND age >= @age", sql.Named("name", "3"), sql.Named("age", 24)).Find(&users)
+	if len(users) != 1 || users[0].Age != 24 {
+		t.Errorf("Should find user with sql.Named parameters, but got %+v", users)
+	}
+
+	users = []User{}
+	db.Where("name = @name OR name IN (@names)", map[string]any{"name": "1", "names": []string{"2", "5"}}).Find(&users)
+	if len(users) != 3 {
+		t.Errorf("Should find users with map parameters, but have %v", len(users))
+	}
+
+	users = []User{}
+	db.Where("name = @Name AND age = @age", User{Name: "3", Age: 22}).Find(&users)
+	if len(users) != 1 || users[0].Age != 22 {
+		t.Errorf("Should find user with struct parameters, but got %+v", users)
+	}
+
+	db.Save(&User{Name: "named", Age: 1, Birthday: t1})
+	db.Exec("UPDATE users SET age = @age WHERE name = @name", sql.Named("age", 30), sql.Named("name", "named"))
+	var user User
+	db.First(&user, "name = ?", "named")
+	if user.Age != 30 {
+		t.Errorf("Should update user with named parameters in exec, but got %+v", user)
+	}
+
+	db.Exec("UPDATE users SET age = age + 1 WHERE name = @name", sql.Named("name", "named"))
+	users = []User{}
+	db.Where("name = @name", sql.Named("name", "named")).Find(&users)
+	if len(users) != 1 || users[0].Age != 31 {
+		t.Errorf("Should bind a single sql.Named parameter, but got %+v", users)
+	}
+}
+
+func TestSelectAndOmit(t *testing.T) {
+	var user User
+	db.Select("name", "age").First(&user, "name = ?", "1")
+	if user.Name != "1" || user.Age != 18 || user.Id != 0 || !user.Birthday.IsZero() {
+		t.Errorf("Should only fetch selected columns, but got %+v", user)
+	}
+
+	user = User{}
+	db.Omit("birthday").First(&user, "name = ?", "1")
+	if user.Name != "1" || user.Id == 0 || !user.Birthday.IsZero() {
+		t.Errorf("Should fetch all columns but omitted ones, but got %+v", user)
+	}
+
+	u := User{Name: "select_save", Age: 10, Birthday: t1}
+	db.Save(&u)
+	u.Name, u.Age = "select_save_new", 20
+	db.Select("age").Save(&u)
+	var found User
+	db.First(&found, u.Id)
+	if found.Name != "select_save" || found.Age != 20 {
+		t.Errorf("Should only update selected columns, but got %+v", found)
+	}
+
+	db.Omit("Age").Save(&User{Name: "omit_save", Age: 10, Birthday: t1})
+	found = User{}
+	db.First(&found, "name = ?", "omit_save")
+	if found.Age != 0 {
+		t.Errorf("Should not write omitted columns, but got %+v", found)
+	}
+}
+
+func TestLimitOffsetGroupHavingDistinct(t *testing.T) {
+	var users []User
+	db.Order("age").Limit(2).Find(&users)
+	if len(users) != 2 {
+		t.Errorf("Should only find 2 users with limit, but have %v", len(users))
+	}
+
+	var all, offset []User
+	db.Where("name in (?)", []string{"1", "2", "3"}).Order("age").Find(&all)
+	db.Where("name in (?)", []string{"1", "2", "3"}).Order("age").Offset(1).Find(&offset)
+	if len(offset) != len(all)-1 || offset[0].Id != all[1].Id {
+		t.Errorf("Should skip the first record with offset, but got %+v", offset)
+	}
+
+	users = []User{}
+	db.Select("name, sum(age) as age").Where("name in (?)", []string{"1", "3"}).Group("name").Having("sum(age) > ?", 40).Find(&users)
+	if len(users) != 1 || users[0].Name != "3" || users[0].Age != 46 {
+		t.Errorf("Should group and filter with having, but got %+v", users)
+	}
+
+	users = []User{}
+	db.Distinct("name").Where("name = ?", "3").Find(&users)
+	if len(users) != 1 {
+		t.Errorf("Should find distinct names, but got %+v", users)
+	}
+
+	db.Save(&User{Name: "limit_write", Age: 1})
+	db.Save(&User{Name: "limit_write", Age: 2})
+	scope := db.Where("name = ?", "limit_write").Group("name").Having("count(*) > 0").Order("age").Offset(1)
+	if err := scope.Limit(1).Model(&User{}).Update("age", 10).Error; err != nil {
+		t.Errorf("Should update without group, having and offset, but got %v", err)
+	}
+	var ages []int64
+	db.Model(&User{}).Where("name = ?", "limit_write").Order("age").Pluck("age", &ages)
+	if len(ages) != 2 || ages[0] != 2 || ages[1] != 10 {
+		t.Errorf("Should only update the first ordered user, but got %v", ages)
+	}
+	if err := scope.Delete(&User{}).Error; err != nil {
+		t.Errorf("Should delete without group, having and offset, but got %v", err)
+	}
+	var count int64
+	db.Model(&User{}).Where("name = ?", "limit_write").Count(&count)
+	if count != 0 {
+		t.Errorf("Should delete all matched users, but %v left", count)
+	}
+}
+
+func TestJoins(t *testing.T) {
+	company := Company{Name: "acme"}
+	db.Save(&company)
+	if company.Id == 0 || company.CreatedAt.IsZero() {
+		t.Errorf("Should save fields of embedded struct, but got %+v", company)
+	}
+	db.Save(&Employee{Name: "joins1", CompanyId: company.Id})
+	db.Save(&Employee{Name: "joins2"})
+
+	var employees []Employee
+	db.Select("employees.*, companies.id AS company__id, companies.name AS company__name").
+		Joins("LEFT JOIN companies ON companies.id = employees.company_id").
+		Where("employees.name IN (?)", []string{"joins1", "joins2"}).
+		Order("employees.name").
+		Find(&employees)
+	if len(employees) != 2 {
+		t.Fatalf("Should find 2 employees with joins, but have %v", len(employees))
+	}
+	if employees[0].Company == nil || employees[0].Company.Name != "acme" || employees[0].Company.Id != company.Id {
+		t.Errorf("Should scan joined columns into nested struct, but got %+v", employees[0].Company)
+	}
+	if employees[1].Company != nil {
+		t.Errorf("Should leave nested struct nil when joined columns are NULL, but got %+v", employees[1].Company)
+	}
+
+	var employee Employee
+	db.Joins("JOIN companies ON companies.id = employees.company_id AND companies.name = ?", "acme").First(&employee)
+	if employee.Name != "joins1" {
+		t.Errorf("Should filter with inner join, but got %+v", employee)
+	}
+
+	var found Company
+	db.First(&found, company.Id)
+	if found.Name != "acme" || found.CreatedAt.IsZero() {
+		t.Errorf("Should scan columns into embedded struct, but got %+v", found)
+	}
+
+	employees = []Employee{}
+	db.Joins("JOIN companies ON companies.id = employees.company_id").Where(map[string]any{"name": "joins1"}).Find(&employees)
+	if len(employees) != 1 || employees[0].Name != "joins1" {
+		t.Errorf("Should qualify map condition columns with joins, but got %+v", employees)
+	}
+
+	employees = []Employee{}
+	db.Joins("JOIN companies ON companies.id = employees.company_id").Not("name", []string{"joins2"}).Find(&employees)
+	if len(employees) != 1 || employees[0].Name != "joins1" {
+		t.Errorf("Should qualify bare column conditions with joins, but got %+v", employees)
+	}
+
+	branch := Branch{Name: "nil_embedded"}
+	if err := db.Save(&branch).Error; err != nil || branch.BaseModel == nil || branch.Id == 0 {
+		t.Errorf("Should save struct with nil embedded pointer, but got %+v, %v", branch.BaseModel, err)
+	}
+	var count int64
+	db.Model(&Branch{}).Where(Branch{Name: "nil_embedded"}).Count(&count)
+	if count != 1 {
+		t.Errorf("Should treat fields of nil embedded pointer as zero in struct condition, but got %v", count)
+	}
+	db.Model(&Branch{}).Where("name = @Name AND id <> @Id", Branch{Name: "nil_embedded"}).Count(&count)
+	if count != 0 {
+		t.Errorf("Should treat fields of nil embedded pointer as NULL in named parameters, but got %v", count)
+	}
+}
+
+func TestCountPluckAndAggregates(t *testing.T) {
+	for _, age := range []int64{10, 20, 30} {
+		db.Save(&User{Name: "aggregate", Age: age})
+	}
+	db.Save(&User{Name: "aggregate_other", Age: 40})
+
+	var count int64
+	db.Model(&User{}).Where("name = ?", "aggregate").Count(&count)
+	if count != 3 {
+		t.Errorf("Should count 3 users, but got %v", count)
+	}
+	db.Table("users").Where("name LIKE ?", "aggregate%").Count(&count)
+	if count != 4 {
+		t.Errorf("Should count with table name, but got %v", count)
+	}
+	db.Model(&User{}).Where("name LIKE ?", "aggregate%").Group("name").Count(&count)
+	if count != 2 {
+		t.Errorf("Should count groups, but got %v", count)
+	}
+
+	var ages []int64
+	db.Model(&User{}).Where("name = ?", "aggregate").Order("age desc").Pluck("age", &ages)
+	if !reflect.DeepEqual(ages, []int64{30, 20, 10}) {
+		t.Errorf("Should pluck ages, but got %v", ages)
+	}
+	var names []string
+	db.Table("users").Where("name LIKE ?", "aggregate%").Distinct().Order("name").Pluck("name", &names)
+	if !reflect.DeepEqual(names, []string{"aggregate", "aggregate_other"}) {
+		t.Errorf("Should pluck distinct names, but got %v", names)
+	}
+
+	var sum, min, max int64
+	var avg float64
+	scope := db.Model(&User{}).Where("name = ?", "aggregate")
+	scope.Sum("age", &sum).Min("age", &min).Max("age", &max).Avg("age", &avg)
+	if sum != 60 || min != 10 || max != 30 || avg != 20 {
+		t.Errorf("Should aggregate ages, but got sum %v, min %v, max %v, avg %v", sum, min, max, avg)
+	}
+
+	sum = 1
+	db.Model(&User{}).Where("name = ?", "aggregate_none").Sum("age", &sum)
+	if sum != 0 {
+		t.Errorf("Should set zero when there is nothing to sum, but got %v", sum)
+	}
+
+	if err := db.Where("name = ?", "aggregate").Count(&count).Error; err == nil {
+		t.Errorf("Should get an error when counting without model or table")
+	}
+}
+
+func TestRawAndScan(t *testing.T) {
+	db.Save(&User{Name: "scan", Age: 5})
+	db.Save(&User{Name: "scan", Age: 7})
+
+	type AgeStat struct {
+		Name  string
+		Total int64 `gormysql:"column:total"`
+		Note  sql.NullString
+	}
+	var stats []AgeStat
+	db.Raw("SELECT name, 'ignored' AS extra, sum(age) AS total, NULL AS note FROM users WHERE name = ? GROUP BY name", "scan").Scan(&stats)
+	if len(stats) != 1 || stats[0].Name != "scan" || stats[0].Total != 12 || stats[0].Note.Valid {
+		t.Errorf("Should scan raw query into struct, but got %+v", stats)
+	}
+
+	var stat AgeStat
+	if err := db.Raw("SELECT sum(age) AS total, name FROM users WHERE name = ? GROUP BY name", "scan").Scan(&stat).Error; err != nil || stat.Total != 12 {
+		t.Errorf("Should scan raw query into single struct, but got %+v, %v", stat, err)
+	}
+
+	var rows []map[string]any
+	db.Raw("SELECT name, age FROM users WHERE name = ? ORDER BY age", "scan").Scan(&rows)
+	if len(rows) != 2 || rows[0]["name"] != "scan" || fmt.Sprint(rows[1]["age"]) != "7" {
+		t.Errorf("Should scan raw query into maps, but got %+v", rows)
+	}
+
+	var row map[string]any
+	db.Raw("SELECT name, NULL AS nothing FROM users WHERE name = ?", "scan").Scan(&row)
+	if row["name"] != "scan" || row["nothing"] != nil {
+		t.Errorf("Should scan raw query into map, but got %+v", row)
+	}
+
+	var ages []int64
+	db.Raw("SELECT age, name FROM users WHERE name IN (?) ORDER BY age", []string{"scan"}).Scan(&ages)
+	if !reflect.DeepEqual(ages, []int64{5, 7}) {
+		t.Errorf("Should scan first column into scalars, but got %v", ages)
+	}
+
+	var users []User
+	db.Raw("SELECT *, 1 AS unknown FROM users WHERE name = ? ORDER BY age", "scan").Find(&users)
+	if len(users) != 2 || users[0].Age != 5 || users[1].Name != "scan" {
+		t.Errorf("Should discard unknown columns when finding, but got %+v", users)
+	}
+
+	var names []string
+	db.Table("users").Select("name").Where("name = ? AND age = ?", "scan", 7).Scan(&names)
+	if len(names) == 0 || names[0] != "scan" {
+		t.Errorf("Should scan built query, but got %v", names)
+	}
+}
+
+func TestRowsAndScanRows(t *testing.T) {
+	db.Save(&User{Name: "rows", Age: 1})
+	db.Save(&User{Name: "rows", Age: 2})
+
+	rows, err := db.Model(&User{}).Where("name = ?", "rows").Order("age").Rows()
+	if err != nil {
+		t.Fatalf("Should get rows, but got %v", err)
+	}
+	defer rows.Close()
+
+	var ages []int64
+	for rows.Next() {
+		var user User
+		if err := db.ScanRows(rows, &user); err != nil {
+			t.Errorf("Should scan row, but got %v", err)
+		}
+		ages = append(ages, user.Age)
+	}
+	if !reflect.DeepEqual(ages, []int64{1, 2}) {
+		t.Errorf("Should scan every row, but got %v", ages)
+	}
+
+	if _, err := db.Where("name = ?", "rows").Rows(); err == nil {
+		t.Errorf("Should get an error when querying rows without model or table")
+	}
+}
+
+func TestFindInBatches(t *testing.T) {
+	for i := 1; i <= 5; i++ {
+		db.Save(&User{Name: "batches", Age: int64(i)})
+	}
+	db.Save(&User{Name: "batches_other", Age: 1})
+
+	var users []User
+	var batches []int
+	var ages []int64
+	db.Where("name = ?", "batches").FindInBatches(&users, 2, func(tx *gormysql.DB, batch int) error {
+		batches = append(batches, batch)
+		for _, user := range users {
+			ages = append(ages, user.Age)
+			user.Age += 10
+			tx.Save(&user)
+		}
+		return nil
+	})
+	if !reflect.DeepEqual(batches, []int{1, 2, 3}) || !reflect.DeepEqual(ages, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("Should find users in batches, but got batches %v with ages %v", batches, ages)
+	}
+	var count int64
+	db.Model(&User{}).Where("name = ? AND age > ?", "batches", 10).Count(&count)
+	if count != 5 {
+		t.Errorf("Should update users through the batch handle, but got %v", count)
+	}
+
+	ages = nil
+	db.Where("name = ?", "batches").Offset(1).Limit(3).FindInBatches(&users, 2, func(tx *gormysql.DB, batch int) error {
+		for _, user := range users {
+			ages = append(ages, user.Age)
+		}
+		return nil
+	})
+	if !reflect.DeepEqual(ages, []int64{12, 13, 14}) {
+		t.Errorf("Should skip records before the first batch with offset, but got ages %v", ages)
+	}
+
+	batches = nil
+	stop := errors.New("stop")
+	err := db.Where("name = ?", "batches").FindInBatches(&users, 2, func(tx *gormysql.DB, batch int) error {
+		batches = append(batches, batch)
+		return stop
+	}).Error
+	if err != stop || len(batches) != 1 {
+		t.Errorf("Should stop at the first error, but got %v after %v", err, batches)
+	}
+
+	var memberships []Membership
+	for i := int64(1); i <= 3; i++ {
+		db.Save(&Membership{UserId: 900, GroupId: i, Role: "batches"})
+	}
+	var groups []int64
+	db.Where("role = ?", "batches").FindInBatches(&memberships, 2, func(tx *gormysql.DB, batch int) error {
+		for _, membership := range memberships {
+			groups = append(groups, membership.GroupId)
+		}
+		return nil
+	})
+	if !reflect.DeepEqual(groups, []int64{1, 2, 3}) {
+		t.Errorf("Should find records with composite keys in batches, but got %v", groups)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var user User
+	if err := db.First(&user, "name = ?", "no such user").Error; !errors.Is(err, gormysql.ErrRecordNotFound) {
+		t.Errorf("Should get ErrRecordNotFound, but got %v", err)
+	}
+
+	db.Save(&Tag{Name: "errors_duplicated"})
+	err := db.Save(&Tag{Name: "errors_duplicated"}).Error
+	if !errors.Is(err, gormysql.ErrDuplicatedKey) {
+		t.Errorf("Should get ErrDuplicatedKey, but got %v", err)
+	}
+	var sqlErr *gormysql.SqlError
+	if !errors.As(err, &sqlErr) || !strings.HasPrefix(sqlErr.Sql, "INSERT INTO tags") {
+		t.Errorf("Should attach the statement to the error, but got %+v", sqlErr)
+	}
+
+	db.Exec("DROP TABLE IF EXISTS fk_children")
+	db.Exec("DROP TABLE IF EXISTS fk_parents")
+	db.Exec("CREATE TABLE fk_parents (id BIGINT PRIMARY KEY)")
+	db.Exec("CREATE TABLE fk_children (id BIGINT PRIMARY KEY, parent_id BIGINT, FOREIGN KEY (parent_id) REFERENCES fk_parents (id))")
+	err = db.Exec("INSERT INTO fk_children (id, parent_id) VALUES (?, ?)", 1, 1).Error
+	if !errors.Is(err, gormysql.ErrForeignKeyViolated) {
+		t.Errorf("Should get ErrForeignKeyViolated, but got %v", err)
+	}
+	if errors.Is(err, gormysql.ErrDuplicatedKey) || errors.Is(err, gormysql.ErrDeadlock) || errors.Is(err, gormysql.ErrLockWaitTimeout) {
+		t.Errorf("Should classify the error only once, but got %v", err)
+	}
+
+	var count, all int64
+	db.Model(&User{}).Count(&all)
+	for _, chain := range []*gormysql.Chain{db.Delete(User{Id: 1}), db.Save(User{Name: "errors_copy"}), db.Create(User{Name: "errors_copy"})} {
+		if chain.Error == nil {
+			t.Errorf("Should refuse values that aren't pointers")
+		}
+	}
+	db.Model(&User{}).Count(&count)
+	if count != all {
+		t.Errorf("Should leave records as is when refusing values, but got %v of %v", count, all)
+	}
+}
+
+func TestFieldTypes(t *testing.T) {
+	nickname := "nick"
+	profile := Profile{Nickname: &nickname, Level: 3, Bio: sql.NullString{String: "bio", Valid: true}}
+	if err := db.Save(&profile).Error; err != nil {
+		t.Fatalf("Should save pointer, named and sql.Null fields, but got %v", err)
+	}
+	if profile.Id == nil || *profile.Id == 0 || profile.CreatedAt == nil {
+		t.Fatalf("Should set pointer id and created at, but got %+v", profile)
+	}
+
+	var found Profile
+	db.First(&found, *profile.Id)
+	if found.Nickname == nil || *found.Nickname != "nick" || found.Level != 3 || found.Bio.String != "bio" || found.Score.Valid {
+		t.Errorf("Should find pointer, named and sql.Null fields, but got %+v", found)
+	}
+
+	type Unsupported struct {
+		Id   int64
+		Meta map[string]string
+	}
+	err := db.CreateTable(&Unsupported{}).Error
+	if err == nil || !strings.Contains(err.Error(), "Unsupported.Meta") || !strings.Contains(err.Error(), "map[string]string") {
+		t.Errorf("Should get an error naming the unsupported field, but got %v", err)
+	}
+
+	type TextKey struct {
+		Code string `gormysql:"primary_key;auto_increment"`
+	}
+	if err := db.CreateTable(&TextKey{}).Error; err == nil {
+		t.Errorf("Should get an error for a non integer auto increment key")
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	user := User{Name: "updates", Age: 10}
+	db.Save(&user)
+	other := User{Name: "updates_other", Age: 10}
+	db.Save(&other)
+
+	var partial User
+	db.Select("id, name").First(&partial, user.Id)
+	chain := db.Model(&partial).Update("name", "updates_renamed")
+	if chain.Error != nil || chain.RowsAffected != 1 || partial.Name != "updates_renamed" {
+		t.Errorf("Should update one column, but got %+v, %v rows, %v", partial, chain.RowsAffected, chain.Error)
+	}
+	var found User
+	db.First(&found, user.Id)
+	if found.Name != "updates_renamed" || found.Age != 10 {
+		t.Errorf("Should leave other columns as is, but got %+v", found)
+	}
+
+	db.Model(&found).Updates(map[string]any{"Age": 11, "birthday": time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)})
+	db.Model(&found).Updates(User{Name: "updates", Age: 0})
+	db.First(&found, user.Id)
+	if found.Name != "updates" || found.Age != 11 || found.Birthday.Year() != 2000 {
+		t.Errorf("Should update with map and non zero struct fields, but got %+v", found)
+	}
+
+	db.Model(&User{}).Where("name LIKE ?", "updates%").Update("age", 20)
+	var count int64
+	db.Model(&User{}).Where("name LIKE ? AND age = ?", "updates%", 20).Count(&count)
+	if count != 2 {
+		t.Errorf("Should update the records matching the conditions, but got %v", count)
+	}
+
+	var product Product
+	db.Save(&Product{Code: "update_columns", Price: 10})
+	db.First(&product, "code = ?", "update_columns")
+	updatedAt := product.UpdatedAt
+	time.Sleep(1 * time.Second)
+	db.Model(&product).UpdateColumn("price", 20)
+	if product.BeforeUpdateCallTimes != 0 {
+		t.Errorf("Should not run hooks when updating columns")
+	}
+	db.First(&product, product.Id)
+	if product.Price != 20 || !product.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("Should update column without touching updated at, but got %+v", product)
+	}
+	db.Model(&product).Updates(map[string]any{"price": 30})
+	if product.BeforeUpdateCallTimes != 1 {
+		t.Errorf("Should run hooks when updating")
+	}
+	db.First(&product, product.Id)
+	if product.Price != 30 || product.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("Should update columns with updated at, but got %+v", product)
+	}
+
+	if err := db.Model(&User{}).Update("age", 1).Error; !errors.Is(err, gormysql.ErrMissingWhereClause) {
+		t.Errorf("Should refuse to update without conditions or primary key, but got %v", err)
+	}
+
+	cdb, err := gormysql.Open(dsn)
+	if err != nil {
+		t.Fatalf("No error should happen when connect database, but got %+v", err)
+	}
+	var called []string
+	cdb.Callback().Update().Before("gormysql:update").Register("test:before_update", func(d *gormysql.Do) {
+		called = append(called, d.TableName())
+	})
+	beforeUpdates := product.BeforeUpdateCallTimes
+	cdb.Model(&product).UpdateColumn("price", 40)
+	if len(called) != 1 || called[0] != "products" || product.BeforeUpdateCallTimes != beforeUpdates {
+		t.Errorf("Should run update processors but no hooks when updating columns, but got %v", called)
+	}
+}
+
+func TestCreateSlice(t *testing.T) {
+	users := []User{{Name: "create_slice", Age: 1}, {Name: "create_slice", Age: 2}, {Name: "create_slice", Age: 3}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatalf("Should create users, but got %v", err)
+	}
+	for i, user := range users {
+		if user.Id == 0 || (i > 0 && user.Id != users[i-1].Id+1) || user.CreatedAt.IsZero() {
+			t.Errorf("Should set ids and timestamps of created users, but got %+v", users)
+		}
+	}
+
+	wide := []User{{Name: strings.Repeat("w", 3<<20), Age: 1}, {Name: strings.Repeat("w", 3<<20), Age: 2}}
+	if err := db.Create(&wide).Error; err != nil || wide[0].Id == 0 || wide[1].Id == 0 {
+		t.Errorf("Should create wide rows in batches under the packet size, but got %v", err)
+	}
+	db.Where("id IN (?)", []int64{wide[0].Id, wide[1].Id}).Delete(&User{})
+
+	products := []*Product{{Code: "create_batches", Price: 1}, {Code: "create_batches", Price: 2}, {Code: "create_batches", Price: 3}}
+	chain := db.CreateInBatches(&products, 2)
+	if chain.Error != nil || products[2].Id == 0 || products[0].BeforeCreateCallTimes != 1 || products[2].AfterSaveCallTimes != 1 {
+		t.Errorf("Should create products in batches running hooks, but got %+v, %v", products[2], chain.Error)
+	}
+	var count int64
+	db.Model(&Product{}).Where("code = ?", "create_batches").Count(&count)
+	if count != 3 {
+		t.Errorf("Should find created products, but got %v", count)
+	}
+
+	tags := []Tag{{Name: "create_tag1"}, {Name: "create_tag2", Color: "red"}}
+	db.Save(&tags)
+	var tag Tag
+	db.First(&tag, "tag_name = ?", "create_tag1")
+	if tag.Id != tags[0].Id || tag.Color != "white" {
+		t.Errorf("Should save slices with column defaults, but got %+v", tag)
+	}
+
+	duplicated := []Tag{{Name: "create_tag3"}, {Name: "create_tag4"}, {Name: "create_tag1"}}
+	chain = db.CreateInBatches(&duplicated, 2)
+	if !errors.Is(chain.Error, gormysql.ErrDuplicatedKey) || len(chain.Errors) != 1 {
+		t.Errorf("Should get a duplicated key error once, but got %v", chain.Errors)
+	}
+	if db.First(&tag, "tag_name = ?", "create_tag3").Error == nil {
+		t.Errorf("Should roll back the batches created before an error")
+	}
+	if duplicated[0].Id != 0 || duplicated[1].Id != 0 {
+		t.Errorf("Should reset the ids of rolled back batches, but got %+v", duplicated)
+	}
+
+	chain = db.CreateInBatches(&[]Tag{{Name: "create_tag5"}, {Name: "create_tag1"}}, 2)
+	if !errors.Is(chain.Error, gormysql.ErrDuplicatedKey) || len(chain.Errors) != 1 {
+		t.Errorf("Should get a duplicated key error once from a single batch, but got %v", chain.Errors)
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	tag := Tag{Name: "upsert", Color: "red"}
+	db.OnConflict(gormysql.OnConflict{UpdateAll: true}).Create(&tag)
+	again := Tag{Name: "upsert", Color: "blue"}
+	if err := db.OnConflict(gormysql.OnConflict{UpdateAll: true}).Create(&again).Error; err != nil {
+		t.Fatalf("Should upsert tag, but got %v", err)
+	}
+	if again.Id != tag.Id {
+		t.Errorf("Should get the id of the updated row, but got %v instead of %v", again.Id, tag.Id)
+	}
+	var found Tag
+	db.First(&found, "tag_name = ?", "upsert")
+	if found.Color != "blue" {
+		t.Errorf("Should update all columns on conflict, but got %+v", found)
+	}
+
+	ignored := Tag{Name: "upsert", Color: "green"}
+	if err := db.OnConflict(gormysql.OnConflict{DoNothing: true}).Create(&ignored).Error; err != nil {
+		t.Errorf("Should ignore conflicting rows, but got %v", err)
+	}
+	db.First(&found, "tag_name = ?", "upsert")
+	if found.Color != "blue" {
+		t.Errorf("Should leave conflicting rows as is, but got %+v", found)
+	}
+
+	tags := []Tag{{Name: "upsert", Color: "yellow"}, {Name: "upsert_new", Color: "black"}}
+	db.OnConflict(gormysql.OnConflict{
+		DoUpdates:   []string{"color"},
+		Assignments: map[string]any{"tag_name": gormysql.Expr("CONCAT(tag_name, ?)", "_updated")},
+	}).Create(&tags)
+	var names []string
+	db.Model(&Tag{}).Where("tag_name LIKE ?", "upsert%").Order("tag_name").Pluck("tag_name", &names)
+	if !reflect.DeepEqual(names, []string{"upsert_new", "upsert_updated"}) {
+		t.Errorf("Should insert new rows and assign conflicting ones, but got %v", names)
+	}
+	db.First(&found, "tag_name = ?", "upsert_updated")
+	if found.Color != "yellow" {
+		t.Errorf("Should update the listed columns on conflict, but got %+v", found)
+	}
+
+	product := Product{Code: "upsert_expr", Price: 10}
+	db.Save(&product)
+	db.Model(&product).Update("price", gormysql.Expr("price + ?", 5))
+	db.First(&product, product.Id)
+	if product.Price != 15 {
+		t.Errorf("Should update with expression, but got %v", product.Price)
+	}
+}
+
+func TestFirstOrInitAndFirstOrCreate(t *testing.T) {
+	var user User
+	db.Where(User{Name: "first_or_init"}).Attrs(User{Age: 20}).FirstOrInit(&user)
+	if user.Id != 0 || user.Name != "first_or_init" || user.Age != 20 {
+		t.Errorf("Should init user with conditions and attrs, but got %+v", user)
+	}
+
+	var created bool
+	var err error
+	user = User{}
+	created, err = db.Attrs(map[string]any{"age": 30}).FirstOrCreate(&user, User{Name: "first_or_create"})
+	if err != nil || !created || user.Id == 0 || user.Age != 30 {
+		t.Errorf("Should create user with attrs, but got %+v, %v, %v", user, created, err)
+	}
+	id := user.Id
+
+	user = User{}
+	created, err = db.Attrs(User{Age: 40}).Assign(map[string]any{"birthday": time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)}).
+		FirstOrCreate(&user, "name = ?", "first_or_create")
+	if err != nil || created || user.Id != id || user.Age != 30 || user.Birthday.Year() != 2001 {
+		t.Errorf("Should find user and assign values, but got %+v, %v, %v", user, created, err)
+	}
+	var found User
+	db.First(&found, id)
+	if found.Birthday.Year() != 2001 || found.Age != 30 {
+		t.Errorf("Should store assigned values, but got %+v", found)
+	}
+
+	user = User{}
+	db.Where("name = ?", "first_or_create").Assign(User{Age: 50}).FirstOrInit(&user)
+	db.First(&found, id)
+	if user.Id != id || user.Age != 50 || found.Age != 30 {
+		t.Errorf("Should assign values without storing them, but got %+v and %+v", user, found)
+	}
+
+	var count int64
+	db.Model(&User{}).Where("name = ?", "first_or_create").Count(&count)
+	if count != 1 {
+		t.Errorf("Should create the user only once, but got %v", count)
+	}
+
+	db.Save(&Preference{UserId: 1, Theme: "light"})
+	db.Save(&Preference{UserId: 2, Theme: "light"})
+	var preference Preference
+	created, err = db.Assign(Preference{Theme: "dark"}).FirstOrCreate(&preference, Preference{UserId: 1})
+	if err != nil || created || preference.Theme != "dark" {
+		t.Errorf("Should assign values to record without primary key, but got %+v, %v, %v", preference, created, err)
+	}
+	var themes []string
+	db.Model(&Preference{}).Order("user_id").Pluck("theme", &themes)
+	if !reflect.DeepEqual(themes, []string{"dark", "light"}) {
+		t.Errorf("Should only update the record found, but got %v", themes)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	comment := Comment{Body: "soft_delete"}
+	db.Save(&comment)
+	db.Save(&Comment{Body: "soft_delete"})
+	if err := db.Delete(&comment).Error; err != nil || comment.DeletedAt == nil {
+		t.Fatalf("Should soft delete comment, but got %+v, %v", comment, err)
+	}
+
+	var comments []Comment
+	db.Where("body = ?", "soft_delete").Or("id = ?", comment.Id).Find(&comments)
+	if len(comments) != 1 || comments[0].Id == comment.Id {
+		t.Errorf("Should leave soft deleted records out, but got %+v", comments)
+	}
+	var found Comment
+	if err := db.First(&found, comment.Id).Error; !errors.Is(err, gormysql.ErrRecordNotFound) {
+		t.Errorf("Should not find soft deleted record, but got %v", err)
+	}
+	db.Unscoped().First(&found, comment.Id)
+	if found.Id != comment.Id || found.DeletedAt == nil {
+		t.Errorf("Should find soft deleted record when unscoped, but got %+v", found)
+	}
+
+	if err := db.Restore(&comment).Error; err != nil || comment.DeletedAt != nil {
+		t.Errorf("Should restore comment, but got %+v, %v", comment, err)
+	}
+	var count int64
+	db.Model(&Comment{}).Where("body = ?", "soft_delete").Count(&count)
+	if count != 2 {
+		t.Errorf("Should find restored record, but got %v", count)
+	}
+
+	db.Delete(&comment)
+	for _, chain := range []*gormysql.Chain{db.Restore(&Comment{}), db.Delete(&Comment{}), db.Unscoped().Delete(&Comment{})} {
+		if !errors.Is(chain.Error, gormysql.ErrMissingWhereClause) {
+			t.Errorf("Should refuse to reach every record, but got %v", chain.Error)
+		}
+	}
+	db.Model(&Comment{}).Where("body = ?", "soft_delete").Count(&count)
+	if count != 1 {
+		t.Errorf("Should leave records as is without conditions, but got %v", count)
+	}
+
+	db.Unscoped().Delete(&comment)
+	db.Unscoped().Model(&Comment{}).Where("body = ?", "soft_delete").Count(&count)
+	if count != 1 {
+		t.Errorf("Should delete for good when unscoped, but got %v", count)
+	}
+
+	draft := Draft{Title: "soft_delete"}
+	if err := db.Save(&draft).Error; err != nil {
+		t.Fatalf("Should save draft with zero deleted at, but got %v", err)
+	}
+	db.Delete(&draft)
+	if draft.DeletedAt.IsZero() || db.First(&Draft{}, draft.Id).Error == nil {
+		t.Errorf("Should soft delete draft with time deleted at, but got %+v", draft)
+	}
+
+	coupon := Coupon{Code: "soft_delete", Discount: 10}
+	db.Save(&coupon)
+	db.Delete(&coupon)
+	coupon.Discount = 20
+	if err := db.Save(&coupon).Error; err != nil {
+		t.Errorf("Should update soft deleted record on save, but got %v", err)
+	}
+	var coupons []Coupon
+	db.Unscoped().Where("code = ?", "soft_delete").Find(&coupons)
+	if len(coupons) != 1 || coupons[0].Discount != 20 || coupons[0].DeletedAt == nil {
+		t.Errorf("Should keep soft deleted record deleted after save, but got %+v", coupons)
+	}
+}
+
+func TestEmptySliceConditions(t *testing.T) {
+	db.Save(&User{Name: "empty_slice"})
+
+	var count, all int64
+	db.Model(&User{}).Count(&all)
+	db.Model(&User{}).Where("id IN (?)", []int{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Should match nothing with an empty IN list, but got %v", count)
+	}
+	db.Model(&User{}).Where("id NOT IN ?", []int{}).Count(&count)
+	if count != all {
+		t.Errorf("Should match everything with an empty NOT IN list, but got %v of %v", count, all)
+	}
+	db.Model(&User{}).Not("id", []int{}).Count(&count)
+	if count != all {
+		t.Errorf("Should match everything when negating an empty list, but got %v of %v", count, all)
+	}
+	db.Model(&User{}).Not(map[string]any{"id": []int64{}}).Count(&count)
+	if count != all {
+		t.Errorf("Should match everything when negating an empty map list, but got %v of %v", count, all)
+	}
+
+	var users []User
+	db.Where("name IN ?", []string{"empty_slice", "none"}).Not("name", []string{"none"}).Find(&users)
+	if len(users) != 1 {
+		t.Errorf("Should bind lists without parentheses and bare columns, but got %+v", users)
+	}
 }